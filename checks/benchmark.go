@@ -0,0 +1,152 @@
+package checks
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultBenchmarkIterations is used when the benchmark doesn't define how many times
+// each query should run
+const defaultBenchmarkIterations = 10
+
+// BenchmarkResult holds the latencies of a benchmarked query in milliseconds
+type BenchmarkResult struct {
+	P50 float64
+	P95 float64
+}
+
+// BenchmarkName returns the name of a benchmarked query, used in the datadog tags and
+// the snapshot tags. A query without a name is named after a hash of its text.
+func BenchmarkName(query BenchmarkQueries) string {
+	if query.Name != "" {
+		return query.Name
+	}
+	sum := sha1.Sum([]byte(query.Query))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// BenchmarkTags returns the snapshot tags where the p50 and p95 latencies of a query are stored
+func BenchmarkTags(name string) (string, string) {
+	return "BenchmarkP50:" + name, "BenchmarkP95:" + name
+}
+
+// GetBenchmarkBaseline returns the latencies of a query measured on the newest snapshot
+// of the same instance created before snapshot whose checks didn't fail.
+// It returns false if no previous run measured the query.
+func (c *Client) GetBenchmarkBaseline(snapshot *rds.DBSnapshot, name string) (BenchmarkResult, bool) {
+	snapshots, err := c.GetSnapshots(*snapshot.DBInstanceIdentifier)
+	if err != nil {
+		log.WithError(err).Error("Could not get snapshots")
+		return BenchmarkResult{}, false
+	}
+
+	p50Key, p95Key := BenchmarkTags(name)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		previous := snapshots[i]
+		if *previous.DBSnapshotArn == *snapshot.DBSnapshotArn {
+			continue
+		}
+		if snapshot.SnapshotCreateTime != nil && !previous.SnapshotCreateTime.Before(*snapshot.SnapshotCreateTime) {
+			continue
+		}
+
+		o, err := c.RDS.ListTagsForResource(&rds.ListTagsForResourceInput{
+			ResourceName: aws.String(*previous.DBSnapshotArn),
+		})
+		if err != nil {
+			continue
+		}
+		tags := map[string]string{}
+		for _, t := range o.TagList {
+			tags[*t.Key] = *t.Value
+		}
+		if tags["ChecksFailed"] == "yes" {
+			continue
+		}
+
+		p50, err := strconv.ParseFloat(tags[p50Key], 64)
+		if err != nil {
+			continue
+		}
+		p95, err := strconv.ParseFloat(tags[p95Key], 64)
+		if err != nil {
+			continue
+		}
+		return BenchmarkResult{P50: p50, P95: p95}, true
+	}
+	return BenchmarkResult{}, false
+}
+
+// RunBenchmark runs a query warmup times without measuring it, then iterations times
+// and returns the p50 and p95 latencies. Every row is read so the latency includes
+// fetching the results from the database.
+func (c *Client) RunBenchmark(query string, warmup, iterations int) (BenchmarkResult, error) {
+	if iterations <= 0 {
+		iterations = defaultBenchmarkIterations
+	}
+
+	for i := 0; i < warmup; i++ {
+		_, err := c.timeQuery(query)
+		if err != nil {
+			log.WithError(err).Error("Could not run warm up query")
+			return BenchmarkResult{}, err
+		}
+	}
+
+	latencies := make([]float64, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		latency, err := c.timeQuery(query)
+		if err != nil {
+			log.WithError(err).Error("Could not run benchmark query")
+			return BenchmarkResult{}, err
+		}
+		latencies = append(latencies, latency)
+	}
+
+	sort.Float64s(latencies)
+
+	return BenchmarkResult{
+		P50: percentile(latencies, 50),
+		P95: percentile(latencies, 95),
+	}, nil
+}
+
+// timeQuery runs a query, reads all the rows and returns how long it took in milliseconds
+func (c *Client) timeQuery(query string) (float64, error) {
+	start := time.Now()
+
+	rows, err := c.DB.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(time.Since(start)) / float64(time.Millisecond), nil
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
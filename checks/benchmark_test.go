@@ -0,0 +1,117 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	c := &Client{
+		DB: db,
+	}
+
+	for i := 0; i < 4; i++ {
+		rows := sqlmock.NewRows([]string{"count"}).AddRow(int64(42))
+		mockdb.ExpectQuery("SELECT count").WillReturnRows(rows)
+	}
+
+	value, err := c.RunBenchmark("SELECT count(*) FROM users", 1, 3)
+	assert.Nil(t, err)
+	assert.True(t, value.P95 >= value.P50)
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
+
+func TestRunBenchmarkQueryFails(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	c := &Client{
+		DB: db,
+	}
+
+	mockdb.ExpectQuery("SELECT count").WillReturnError(sqlmock.ErrCancelled)
+
+	_, err = c.RunBenchmark("SELECT count(*) FROM users", 0, 3)
+	assert.NotNil(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, percentile(values, 50), float64(5))
+	assert.Equal(t, percentile(values, 95), float64(10))
+	assert.Equal(t, percentile([]float64{}, 95), float64(0))
+}
+
+func TestBenchmarkName(t *testing.T) {
+	assert.Equal(t, "users", BenchmarkName(BenchmarkQueries{Name: "users", Query: "SELECT count(*) FROM users"}))
+	assert.Equal(t, BenchmarkName(BenchmarkQueries{Query: "SELECT 1"}), BenchmarkName(BenchmarkQueries{Query: "SELECT 1"}))
+	assert.NotEqual(t, BenchmarkName(BenchmarkQueries{Query: "SELECT 1"}), BenchmarkName(BenchmarkQueries{Query: "SELECT 2"}))
+}
+
+func TestGetBenchmarkBaseline(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	now := time.Now()
+	snapshot := func(name string, age time.Duration) *rds.DBSnapshot {
+		return &rds.DBSnapshot{
+			DBInstanceIdentifier: aws.String("test"),
+			DBSnapshotIdentifier: aws.String(name),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:" + name),
+			SnapshotCreateTime:   aws.Time(now.Add(-age)),
+			Status:               aws.String("available"),
+		}
+	}
+	current := snapshot("current", 0)
+	failed := snapshot("failed", 24*time.Hour)
+	passed := snapshot("passed", 48*time.Hour)
+
+	rdsc.On("DescribeDBSnapshots", &rds.DescribeDBSnapshotsInput{
+		DBInstanceIdentifier: aws.String("test"),
+	}).Return(&rds.DescribeDBSnapshotsOutput{
+		DBSnapshots: []*rds.DBSnapshot{passed, current, failed},
+	}, nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{
+		ResourceName: failed.DBSnapshotArn,
+	}).Return(&rds.ListTagsForResourceOutput{
+		TagList: []*rds.Tag{
+			{Key: aws.String("ChecksFailed"), Value: aws.String("yes")},
+			{Key: aws.String("BenchmarkP50:users"), Value: aws.String("100")},
+			{Key: aws.String("BenchmarkP95:users"), Value: aws.String("300")},
+		},
+	}, nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{
+		ResourceName: passed.DBSnapshotArn,
+	}).Return(&rds.ListTagsForResourceOutput{
+		TagList: []*rds.Tag{
+			{Key: aws.String("ChecksFailed"), Value: aws.String("no")},
+			{Key: aws.String("BenchmarkP50:users"), Value: aws.String("10")},
+			{Key: aws.String("BenchmarkP95:users"), Value: aws.String("30")},
+		},
+	}, nil)
+
+	value, ok := c.GetBenchmarkBaseline(current, "users")
+	assert.True(t, ok)
+	assert.Equal(t, BenchmarkResult{P50: 10, P95: 30}, value)
+
+	_, ok = c.GetBenchmarkBaseline(current, "orders")
+	assert.False(t, ok)
+}
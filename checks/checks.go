@@ -2,6 +2,7 @@ package checks
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 
@@ -9,18 +10,31 @@ import (
 
 	"github.com/aws/aws-sdk-go/service/rds"
 	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/config"
 )
 
 var engineType = map[string]string{
 	"postgres": "postgres",
 }
 
+// engineConnection are the connection strings by engine.
+// They take the host, port, user, password, database, sslmode and the path of the certificate authorities bundle.
 var engineConnection = map[string]string{
-	"postgres": "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	"postgres": "host=%s port=%s user=%s password=%s dbname=%s sslmode=%s sslrootcert=%s",
 }
 
-// InitDb initialize the database connection
-func (c *Client) InitDb(db *rds.DBInstance, password, dbname string) error {
+// SSLModes are the supported ssl modes to connect to a restored instance
+var SSLModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-full": true,
+}
+
+// InitDb initialize the database connection.
+// sslmode is one of SSLModes. With require and verify-full the server certificate is checked
+// against the RDS certificate authorities, verify-full also checks the hostname.
+// They fail if the certificate authorities bundle is missing.
+func (c *Client) InitDb(db *rds.DBInstance, password, dbname, sslmode string) error {
 	port := strconv.FormatInt(*db.Endpoint.Port, 10)
 	host := *db.Endpoint.Address
 	rdsEngine := *db.Engine
@@ -32,11 +46,23 @@ func (c *Client) InitDb(db *rds.DBInstance, password, dbname string) error {
 		engine = v
 	}
 
+	if !SSLModes[sslmode] {
+		return fmt.Errorf("Unsupported sslmode %q", sslmode)
+	}
+
+	// lib/pq silently skips the certificate verification when sslrootcert doesn't exist
+	if sslmode != "disable" {
+		_, err := os.Stat(config.RDSCABundle)
+		if err != nil {
+			return fmt.Errorf("Could not read the RDS certificate authorities bundle: %v", err)
+		}
+	}
+
 	var args string
 
 	if e, ok := engineConnection[rdsEngine]; ok {
 		args = fmt.Sprintf(e,
-			host, port, user, password, dbname)
+			host, port, user, password, dbname, sslmode, config.RDSCABundle)
 	}
 
 	var err error
@@ -115,3 +141,13 @@ func (c *Client) CheckRegexAgainstRow(query, regex string) bool {
 	}
 	return false
 }
+
+// RunScript runs a sql script, which can contain several statements, on the database
+func (c *Client) RunScript(script string) error {
+	_, err := c.DB.Exec(script)
+	if err != nil {
+		log.WithError(err).Error("Could not run the script")
+		return err
+	}
+	return nil
+}
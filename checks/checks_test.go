@@ -4,9 +4,50 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/stretchr/testify/assert"
+	"github.com/techdroplabs/rdscheck/config"
 )
 
+func TestInitDbUnsupportedSSLMode(t *testing.T) {
+	c := &Client{}
+
+	db := &rds.DBInstance{
+		Endpoint: &rds.Endpoint{
+			Address: aws.String("test.rds.amazonaws.com"),
+			Port:    aws.Int64(5432),
+		},
+		Engine:         aws.String("postgres"),
+		MasterUsername: aws.String("postgres"),
+	}
+
+	err := c.InitDb(db, "password", "test", "allow")
+	assert.NotNil(t, err)
+	assert.Nil(t, c.DB)
+}
+
+func TestInitDbMissingCABundle(t *testing.T) {
+	c := &Client{}
+
+	bundle := config.RDSCABundle
+	config.RDSCABundle = "/nonexistent/rds-ca-bundle.pem"
+	defer func() { config.RDSCABundle = bundle }()
+
+	db := &rds.DBInstance{
+		Endpoint: &rds.Endpoint{
+			Address: aws.String("test.rds.amazonaws.com"),
+			Port:    aws.Int64(5432),
+		},
+		Engine:         aws.String("postgres"),
+		MasterUsername: aws.String("postgres"),
+	}
+
+	err := c.InitDb(db, "password", "test", "require")
+	assert.NotNil(t, err)
+	assert.Nil(t, c.DB)
+}
+
 func TestCheckRegexAgainstRow_stringTrue(t *testing.T) {
 	db, mockdb, err := sqlmock.New()
 	if err != nil {
@@ -96,3 +137,21 @@ func TestCheckRegexAgainstRow_int64False(t *testing.T) {
 	value := c.CheckRegexAgainstRow("SELECT number FROM database", "^99$")
 	assert.False(t, value)
 }
+
+func TestRunScript(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	c := &Client{
+		DB: db,
+	}
+
+	mockdb.ExpectExec("UPDATE users SET email").WillReturnResult(sqlmock.NewResult(0, 42))
+
+	err = c.RunScript("UPDATE users SET email = md5(email) || '@example.com';")
+	assert.Nil(t, err)
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
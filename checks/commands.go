@@ -3,6 +3,7 @@ package checks
 import (
 	"errors"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -40,11 +41,11 @@ func (c *Client) GetSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, e
 }
 
 // CopySnapshots copies the snapshots either to the same region as the original
-// or to a new region
-func (c *Client) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string) error {
+// or to a new region. With encrypt, an unencrypted snapshot is encrypted with kmsid in the destination.
+func (c *Client) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string, encrypt bool) error {
 
 	input := &rds.CopyDBSnapshotInput{
-		SourceRegion:               aws.String(config.AWSRegionSource),
+		SourceRegion:               aws.String(snapshotRegion(*snapshot.DBSnapshotArn)),
 		DestinationRegion:          aws.String(destination),
 		SourceDBSnapshotIdentifier: aws.String(*snapshot.DBSnapshotArn),
 		TargetDBSnapshotIdentifier: aws.String(cleanArn),
@@ -65,12 +66,22 @@ func (c *Client) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, pre
 				Key:   aws.String("ChecksFailed"),
 				Value: aws.String("no"),
 			},
+			{
+				Key:   aws.String("SourceType"),
+				Value: aws.String(sourceType(snapshot)),
+			},
+			{
+				Key:   aws.String("SourceCreateTime"),
+				Value: aws.String(snapshot.SnapshotCreateTime.UTC().Format(time.RFC3339)),
+			},
 		},
 	}
 
 	if *snapshot.Encrypted {
 		input.PreSignedUrl = aws.String(preSignedUrl)
 		input.KmsKeyId = aws.String(kmsid)
+	} else if encrypt {
+		input.KmsKeyId = aws.String(kmsid)
 	}
 
 	_, err := c.RDS.CopyDBSnapshot(input)
@@ -91,7 +102,7 @@ func (c *Client) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, pre
 	} else {
 		log.WithFields(log.Fields{
 			"Snapshot":    *snapshot.DBSnapshotIdentifier,
-			"From":        config.AWSRegionSource,
+			"From":        snapshotRegion(*snapshot.DBSnapshotArn),
 			"Destination": destination,
 		}).Info("Snapshot copied")
 	}
@@ -101,7 +112,7 @@ func (c *Client) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, pre
 // PreSignUrl presigned an aws url so that we can copy an encrypted snapshot from a region to another
 func (c *Client) PreSignUrl(destinationRegion, snapshotArn, kmsid, cleanArn string) (string, error) {
 	input := &rds.CopyDBSnapshotInput{
-		SourceRegion:               aws.String(config.AWSRegionSource),
+		SourceRegion:               aws.String(snapshotRegion(snapshotArn)),
 		DestinationRegion:          aws.String(destinationRegion),
 		SourceDBSnapshotIdentifier: aws.String(snapshotArn),
 		KmsKeyId:                   aws.String(kmsid),
@@ -199,20 +210,22 @@ func (c *Client) CreateDatabaseSubnetGroup(snapshot *rds.DBSnapshot, subnetids [
 	return nil
 }
 
-// CreateDBFromSnapshot creates the RDS instance from a snapshot
-func (c *Client) CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string) error {
+// CreateDBFromSnapshot creates the RDS instance from a snapshot.
+// iamauth enables the IAM database authentication on the restored instance.
+func (c *Client) CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string, iamauth bool) error {
 
 	input := &rds.RestoreDBInstanceFromDBSnapshotInput{
-		AutoMinorVersionUpgrade: aws.Bool(false),
-		DBInstanceClass:         aws.String(instancetype),
-		DBInstanceIdentifier:    aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
-		DBSnapshotIdentifier:    aws.String(*snapshot.DBSnapshotIdentifier),
-		DBSubnetGroupName:       aws.String(*snapshot.DBSnapshotIdentifier),
-		DeletionProtection:      aws.Bool(false),
-		Engine:                  aws.String(*snapshot.Engine),
-		MultiAZ:                 aws.Bool(false),
-		Port:                    aws.Int64(*snapshot.Port),
-		PubliclyAccessible:      aws.Bool(false),
+		AutoMinorVersionUpgrade:         aws.Bool(false),
+		DBInstanceClass:                 aws.String(instancetype),
+		DBInstanceIdentifier:            aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
+		DBSnapshotIdentifier:            aws.String(*snapshot.DBSnapshotIdentifier),
+		DBSubnetGroupName:               aws.String(*snapshot.DBSnapshotIdentifier),
+		DeletionProtection:              aws.Bool(false),
+		EnableIAMDatabaseAuthentication: aws.Bool(iamauth),
+		Engine:                          aws.String(*snapshot.Engine),
+		MultiAZ:                         aws.Bool(false),
+		Port:                            aws.Int64(*snapshot.Port),
+		PubliclyAccessible:              aws.Bool(false),
 		Tags: []*rds.Tag{
 			{
 				Key:   aws.String("CreatedBy"),
@@ -248,6 +261,15 @@ func (c *Client) DeleteDB(snapshot *rds.DBSnapshot) error {
 
 	_, err := c.RDS.DeleteDBInstance(input)
 	if err != nil {
+		// a previous run may have deleted the instance already
+		if aerr, ok := err.(awserr.Error); ok {
+			if aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+				return nil
+			}
+			if aerr.Code() == rds.ErrCodeInvalidDBInstanceStateFault && c.GetDBInstanceStatus(snapshot) == "deleting" {
+				return nil
+			}
+		}
 		return err
 	}
 
@@ -338,6 +360,9 @@ func (c *Client) ChangeDBpassword(snapshot *rds.DBSnapshot, DBArn, password stri
 	if err != nil {
 		return err
 	}
+	if config.DryRun {
+		return nil
+	}
 
 	statusOk := false
 
@@ -355,6 +380,121 @@ func (c *Client) ChangeDBpassword(snapshot *rds.DBSnapshot, DBArn, password stri
 	return nil
 }
 
+// UpgradeDB upgrades the engine of the rds instance restored from a snapshot to engineVersion.
+// Major version upgrades are allowed and applied immediately.
+func (c *Client) UpgradeDB(snapshot *rds.DBSnapshot, engineVersion string) error {
+	input := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier:     aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
+		EngineVersion:            aws.String(engineVersion),
+		AllowMajorVersionUpgrade: aws.Bool(true),
+		ApplyImmediately:         aws.Bool(true),
+	}
+	_, err := c.RDS.ModifyDBInstance(input)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"RDS Instance":   *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+		"Engine Version": engineVersion,
+	}).Info("Upgrade started")
+	return nil
+}
+
+// CreateSanitizedSnapshot takes a manual snapshot of the rds instance restored from a snapshot
+// and returns its identifier
+func (c *Client) CreateSanitizedSnapshot(snapshot *rds.DBSnapshot) (string, error) {
+	identifier := *snapshot.DBSnapshotIdentifier + "-sanitized"
+	input := &rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
+		DBSnapshotIdentifier: aws.String(identifier),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("CreatedBy"),
+				Value: aws.String("rdscheck"),
+			},
+			{
+				Key:   aws.String("Snapshot"),
+				Value: aws.String(*snapshot.DBSnapshotIdentifier),
+			},
+			{
+				Key:   aws.String("Sanitized"),
+				Value: aws.String("yes"),
+			},
+			{
+				Key:   aws.String("RDS Instance"),
+				Value: aws.String(*snapshot.DBInstanceIdentifier),
+			},
+		},
+	}
+	_, err := c.RDS.CreateDBSnapshot(input)
+	if err != nil {
+		return "", err
+	}
+	return identifier, nil
+}
+
+// GetSanitizedSnapshots returns the sanitized snapshots of the restores of an instance, oldest first
+func (c *Client) GetSanitizedSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error) {
+	input := &rds.DescribeDBSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	}
+	o, err := c.RDS.DescribeDBSnapshots(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var sanitized []*rds.DBSnapshot
+	for _, snapshot := range o.DBSnapshots {
+		if !strings.HasSuffix(*snapshot.DBSnapshotIdentifier, "-sanitized") {
+			continue
+		}
+		if c.CheckTag(*snapshot.DBSnapshotArn, "Sanitized", "yes") &&
+			c.CheckTag(*snapshot.DBSnapshotArn, "RDS Instance", DBInstanceIdentifier) {
+			sanitized = append(sanitized, snapshot)
+		}
+	}
+
+	sort.Slice(sanitized, func(i, j int) bool {
+		return (*sanitized[i].SnapshotCreateTime).Before(*sanitized[j].SnapshotCreateTime)
+	})
+	return sanitized, nil
+}
+
+// GetSnapshotStatus returns the status of a snapshot or an empty string if it doesn't exist
+func (c *Client) GetSnapshotStatus(DBSnapshotIdentifier string) string {
+	input := &rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String(DBSnapshotIdentifier),
+	}
+	o, err := c.RDS.DescribeDBSnapshots(input)
+	if err != nil {
+		return ""
+	}
+	for _, snapshot := range o.DBSnapshots {
+		return *snapshot.Status
+	}
+	return ""
+}
+
+// ShareSnapshot allows other aws accounts to restore a manual snapshot
+func (c *Client) ShareSnapshot(DBSnapshotIdentifier string, accounts []string) error {
+	input := &rds.ModifyDBSnapshotAttributeInput{
+		AttributeName:        aws.String("restore"),
+		DBSnapshotIdentifier: aws.String(DBSnapshotIdentifier),
+		ValuesToAdd:          aws.StringSlice(accounts),
+	}
+	_, err := c.RDS.ModifyDBSnapshotAttribute(input)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Snapshot": DBSnapshotIdentifier,
+		"Accounts": accounts,
+	}).Info("Snapshot shared")
+	return nil
+}
+
 // GetDBInstanceStatus returns the status of a rds instance
 func (c *Client) GetDBInstanceStatus(snapshot *rds.DBSnapshot) string {
 	input := &rds.DescribeDBInstancesInput{
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
@@ -74,6 +75,11 @@ func (m *mockRDS) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds
 	return args.Get(0).(*rds.DescribeDBInstancesOutput), args.Error(1)
 }
 
+func (m *mockRDS) DescribeDBInstanceAutomatedBackups(input *rds.DescribeDBInstanceAutomatedBackupsInput) (*rds.DescribeDBInstanceAutomatedBackupsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.DescribeDBInstanceAutomatedBackupsOutput), args.Error(1)
+}
+
 func (m *mockRDS) DeleteDBSubnetGroup(input *rds.DeleteDBSubnetGroupInput) (*rds.DeleteDBSubnetGroupOutput, error) {
 	args := m.Called(input)
 	return args.Get(0).(*rds.DeleteDBSubnetGroupOutput), args.Error(1)
@@ -84,6 +90,31 @@ func (m *mockRDS) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.Modif
 	return args.Get(0).(*rds.ModifyDBInstanceOutput), args.Error(1)
 }
 
+func (m *mockRDS) RestoreDBInstanceToPointInTime(input *rds.RestoreDBInstanceToPointInTimeInput) (*rds.RestoreDBInstanceToPointInTimeOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.RestoreDBInstanceToPointInTimeOutput), args.Error(1)
+}
+
+func (m *mockRDS) DescribeDBLogFiles(input *rds.DescribeDBLogFilesInput) (*rds.DescribeDBLogFilesOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.DescribeDBLogFilesOutput), args.Error(1)
+}
+
+func (m *mockRDS) DownloadDBLogFilePortion(input *rds.DownloadDBLogFilePortionInput) (*rds.DownloadDBLogFilePortionOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.DownloadDBLogFilePortionOutput), args.Error(1)
+}
+
+func (m *mockRDS) CreateDBSnapshot(input *rds.CreateDBSnapshotInput) (*rds.CreateDBSnapshotOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.CreateDBSnapshotOutput), args.Error(1)
+}
+
+func (m *mockRDS) ModifyDBSnapshotAttribute(input *rds.ModifyDBSnapshotAttributeInput) (*rds.ModifyDBSnapshotAttributeOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*rds.ModifyDBSnapshotAttributeOutput), args.Error(1)
+}
+
 func (m *mockRDS) CopyDBSnapshotRequest(input *rds.CopyDBSnapshotInput) (*request.Request, *rds.CopyDBSnapshotOutput) {
 	args := m.Called(input)
 	return args.Get(0).(*request.Request), args.Get(1).(*rds.CopyDBSnapshotOutput)
@@ -127,6 +158,7 @@ func TestCopySnapshotsNoKms(t *testing.T) {
 	input := &rds.DBSnapshot{
 		DBSnapshotIdentifier: aws.String("test"),
 		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
 		Encrypted:            aws.Bool(false),
 	}
 
@@ -134,12 +166,37 @@ func TestCopySnapshotsNoKms(t *testing.T) {
 		DBSnapshot: &rds.DBSnapshot{},
 	}, nil)
 
-	err := c.CopySnapshots(input, "us-west-2", "", "", "test")
+	err := c.CopySnapshots(input, "us-west-2", "", "", "test", false)
 	assert.Nil(t, err)
 	rdsc.AssertExpectations(t)
 
 }
 
+func TestCopySnapshotsEncrypt(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
+		Encrypted:            aws.Bool(false),
+	}
+
+	rdsc.On("CopyDBSnapshot", mock.MatchedBy(func(input *rds.CopyDBSnapshotInput) bool {
+		return *input.KmsKeyId == "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456" && input.PreSignedUrl == nil
+	})).Return(&rds.CopyDBSnapshotOutput{
+		DBSnapshot: &rds.DBSnapshot{},
+	}, nil)
+
+	err := c.CopySnapshots(input, "us-west-2", "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456", "", "test", true)
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
 func TestCopySnapshotsWithKms(t *testing.T) {
 	rdsc := &mockRDS{}
 
@@ -150,6 +207,7 @@ func TestCopySnapshotsWithKms(t *testing.T) {
 	input := &rds.DBSnapshot{
 		DBSnapshotIdentifier: aws.String("test"),
 		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
 		KmsKeyId:             aws.String("arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456"),
 		Encrypted:            aws.Bool(true),
 	}
@@ -158,7 +216,7 @@ func TestCopySnapshotsWithKms(t *testing.T) {
 		DBSnapshot: &rds.DBSnapshot{},
 	}, nil)
 
-	err := c.CopySnapshots(input, "us-west-2", "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456", "https://url.local", "test")
+	err := c.CopySnapshots(input, "us-west-2", "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456", "https://url.local", "test", false)
 	assert.Nil(t, err)
 	rdsc.AssertExpectations(t)
 
@@ -275,7 +333,7 @@ func TestCreateDBFromSnapshot(t *testing.T) {
 		DBInstance: &rds.DBInstance{},
 	}, nil)
 
-	err := c.CreateDBFromSnapshot(input, "db.t2.micro", vpcsecuritygroupids)
+	err := c.CreateDBFromSnapshot(input, "db.t2.micro", vpcsecuritygroupids, false)
 	assert.Nil(t, err)
 	rdsc.AssertExpectations(t)
 }
@@ -301,6 +359,37 @@ func TestDeleteDB(t *testing.T) {
 	rdsc.AssertExpectations(t)
 }
 
+func TestDeleteDBAlreadyDeleted(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("instance"),
+		DBSnapshotIdentifier: aws.String("test"),
+	}
+
+	rdsc.On("DeleteDBInstance", mock.Anything).Return(&rds.DeleteDBInstanceOutput{},
+		awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil)).Once()
+	err := c.DeleteDB(input)
+	assert.Nil(t, err)
+
+	rdsc.On("DeleteDBInstance", mock.Anything).Return(&rds.DeleteDBInstanceOutput{},
+		awserr.New(rds.ErrCodeInvalidDBInstanceStateFault, "already being deleted", nil))
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			&rds.DBInstance{
+				DBInstanceStatus: aws.String("deleting"),
+			},
+		},
+	}, nil)
+	err = c.DeleteDB(input)
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
 func TestUpdateTag(t *testing.T) {
 	rdsc := &mockRDS{}
 
@@ -414,6 +503,143 @@ func TestChangeDBpassword(t *testing.T) {
 	rdsc.AssertExpectations(t)
 }
 
+func TestUpgradeDB(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	rdsc.On("ModifyDBInstance", &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier:     aws.String("instance-test"),
+		EngineVersion:            aws.String("12.4"),
+		AllowMajorVersionUpgrade: aws.Bool(true),
+		ApplyImmediately:         aws.Bool(true),
+	}).Return(&rds.ModifyDBInstanceOutput{}, nil)
+
+	err := c.UpgradeDB(input, "12.4")
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
+func TestCreateSanitizedSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	rdsc.On("CreateDBSnapshot", mock.MatchedBy(func(input *rds.CreateDBSnapshotInput) bool {
+		return *input.DBInstanceIdentifier == "instance-test" && *input.DBSnapshotIdentifier == "test-sanitized" &&
+			*input.Tags[3].Key == "RDS Instance" && *input.Tags[3].Value == "instance"
+	})).Return(&rds.CreateDBSnapshotOutput{}, nil)
+
+	value, err := c.CreateSanitizedSnapshot(input)
+	assert.Nil(t, err)
+	assert.Equal(t, value, "test-sanitized")
+	rdsc.AssertExpectations(t)
+}
+
+func TestGetSanitizedSnapshots(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	older := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2020-06-28-03-00-sanitized"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:rds:test-2020-06-28-03-00-sanitized"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -2)),
+	}
+	newer := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2020-06-29-03-00-sanitized"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:rds:test-2020-06-29-03-00-sanitized"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -1)),
+	}
+	other := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:other-2020-06-29-03-00-sanitized"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:rds:other-2020-06-29-03-00-sanitized"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -1)),
+	}
+	manual := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("pre-migration"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:pre-migration"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
+	}
+
+	tags := func(instance string) *rds.ListTagsForResourceOutput {
+		return &rds.ListTagsForResourceOutput{
+			TagList: []*rds.Tag{
+				{Key: aws.String("Sanitized"), Value: aws.String("yes")},
+				{Key: aws.String("RDS Instance"), Value: aws.String(instance)},
+			},
+		}
+	}
+
+	rdsc.On("DescribeDBSnapshots", &rds.DescribeDBSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	}).Return(&rds.DescribeDBSnapshotsOutput{
+		DBSnapshots: []*rds.DBSnapshot{newer, manual, other, older},
+	}, nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{ResourceName: older.DBSnapshotArn}).Return(tags("test"), nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{ResourceName: newer.DBSnapshotArn}).Return(tags("test"), nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{ResourceName: other.DBSnapshotArn}).Return(tags("other"), nil)
+
+	sanitized, err := c.GetSanitizedSnapshots("test")
+	assert.Nil(t, err)
+	assert.Equal(t, []*rds.DBSnapshot{older, newer}, sanitized)
+	rdsc.AssertNotCalled(t, "ListTagsForResource", &rds.ListTagsForResourceInput{ResourceName: manual.DBSnapshotArn})
+}
+
+func TestGetSnapshotStatus(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBSnapshots", mock.Anything).Return(&rds.DescribeDBSnapshotsOutput{
+		DBSnapshots: []*rds.DBSnapshot{
+			&rds.DBSnapshot{
+				Status: aws.String("creating"),
+			},
+		},
+	}, nil)
+
+	value := c.GetSnapshotStatus("test-sanitized")
+	assert.Equal(t, value, "creating")
+	rdsc.AssertExpectations(t)
+}
+
+func TestShareSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("ModifyDBSnapshotAttribute", &rds.ModifyDBSnapshotAttributeInput{
+		AttributeName:        aws.String("restore"),
+		DBSnapshotIdentifier: aws.String("test-sanitized"),
+		ValuesToAdd:          aws.StringSlice([]string{"123456789012"}),
+	}).Return(&rds.ModifyDBSnapshotAttributeOutput{}, nil)
+
+	err := c.ShareSnapshot("test-sanitized", []string{"123456789012"})
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
 func TestGetDBInstanceStatus(t *testing.T) {
 	rdsc := &mockRDS{}
 
@@ -2,16 +2,27 @@ package checks
 
 import (
 	"database/sql"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	_ "github.com/lib/pq"
 	"github.com/techdroplabs/rdscheck/config"
 	"github.com/techdroplabs/rdscheck/utils"
@@ -21,37 +32,77 @@ import (
 
 type DefaultChecks interface {
 	SetSessions(region string)
+	SetRoleSessions(region, roleArn string)
+	SetRegionTag(enabled bool)
 	GetYamlFileFromS3(bucket, key string) (io.Reader, error)
 	UnmarshalYamlFile(body io.Reader) (Doc, error)
 	DataDogSession(apiKey, applicationKey string) *datadog.Client
 	PostDatadogChecks(snapshot *rds.DBSnapshot, metricName, status, cmdName string) error
+	PostDatadogCheckMessage(snapshot *rds.DBSnapshot, metricName, status, message, cmdName string, tags []string) error
+	PostDatadogMetric(snapshot *rds.DBSnapshot, metricName string, value float64, cmdName string, tags []string) error
 	GetSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error)
-	CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string) error
+	CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string, encrypt bool) error
 	GetOldSnapshots(snapshots []*rds.DBSnapshot, retention int) ([]*rds.DBSnapshot, error)
+	GetGFSOldSnapshots(snapshots []*rds.DBSnapshot, gfs GFS) ([]*rds.DBSnapshot, error)
 	DeleteOldSnapshot(snapshot *rds.DBSnapshot) error
 	CheckIfDatabaseSubnetGroupExist(snapshot *rds.DBSnapshot) bool
 	CreateDatabaseSubnetGroup(snapshot *rds.DBSnapshot, subnetids []string) error
-	CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string) error
+	CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string, iamauth bool) error
 	DeleteDB(snapshot *rds.DBSnapshot) error
 	UpdateTag(snapshot *rds.DBSnapshot, key, value string) error
 	CheckTag(arn, key, value string) bool
 	GetDBInstanceInfo(snapshot *rds.DBSnapshot) (*rds.DBInstance, error)
 	DeleteDatabaseSubnetGroup(snapshot *rds.DBSnapshot) error
 	ChangeDBpassword(snapshot *rds.DBSnapshot, DBArn, password string) error
+	UpgradeDB(snapshot *rds.DBSnapshot, engineVersion string) error
+	CreateSanitizedSnapshot(snapshot *rds.DBSnapshot) (string, error)
+	GetSanitizedSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error)
+	GetSnapshotStatus(DBSnapshotIdentifier string) string
+	ShareSnapshot(DBSnapshotIdentifier string, accounts []string) error
+	UnshareSnapshot(DBSnapshotIdentifier string, accounts []string) error
+	GetSnapshot(DBSnapshotIdentifier string) (*rds.DBSnapshot, error)
+	CopyVaultSnapshot(snapshot, staging *rds.DBSnapshot, kmsid, cleanArn string) error
 	GetDBInstanceStatus(snapshot *rds.DBSnapshot) string
 	GetTagValue(arn, key string) string
-	InitDb(db *rds.DBInstance, password, dbname string) error
+	MatchManual(snapshot *rds.DBSnapshot, manual Manual) bool
+	InitDb(db *rds.DBInstance, password, dbname, sslmode string) error
 	CheckRegexAgainstRow(query, regex string) bool
+	RunScript(script string) error
 	PreSignUrl(destinationRegion, snapshotArn, kmsid, cleanArn string) (string, error)
 	CleanArn(snapshot *rds.DBSnapshot) string
+	RunHook(db *rds.DBInstance, password, dbname, sslmode string, hook Hooks) (HookResult, error)
+	RunBenchmark(query string, warmup, iterations int) (BenchmarkResult, error)
+	GetBenchmarkBaseline(snapshot *rds.DBSnapshot, name string) (BenchmarkResult, bool)
+	ScanDBLogs(snapshot *rds.DBSnapshot, patterns []string) ([]LogMatch, error)
+	RunReport(snapshot *rds.DBSnapshot, queries []ReportQueries) Report
+	UploadReport(bucket, key string, report Report) error
+	ExportQuery(query, format, bucket, key string) (int64, error)
+	GetPointInTimeRestore(DBInstanceIdentifier string) (*rds.DBSnapshot, error)
+	PointInTimeRestoreDue(DBInstanceIdentifier string, interval int) bool
+	CreateDBFromPointInTime(DBInstanceIdentifier, instancetype string, vpcsecuritygroupids, subnetids []string, iamauth bool) (time.Time, error)
+	CompareGolden(query Queries, bucket, key string) ([]string, error)
+	AcceptGolden(bucket, key string) error
+	GetPassword(ref string) (string, error)
+	CreateRunPassword(snapshot *rds.DBSnapshot) (string, error)
+	GetRunPassword(snapshot *rds.DBSnapshot) (string, error)
+	DeleteRunPassword(snapshot *rds.DBSnapshot) error
+	BuildAuthToken(db *rds.DBInstance) (string, error)
+	GetKmsKeyArn(kmsid string) (string, error)
+	CreateScheduledSnapshot(DBInstanceIdentifier, DBSnapshotIdentifier string) error
 }
 
 type Client struct {
-	Datadog   *datadog.Client
-	S3        s3iface.S3API
-	Snapshots []*rds.DBSnapshot
-	RDS       rdsiface.RDSAPI
-	DB        *sql.DB
+	Datadog        *datadog.Client
+	S3             s3iface.S3API
+	Snapshots      []*rds.DBSnapshot
+	RDS            rdsiface.RDSAPI
+	DB             *sql.DB
+	SecretsManager secretsmanageriface.SecretsManagerAPI
+	SSM            ssmiface.SSMAPI
+	KMS            kmsiface.KMSAPI
+	Region         string
+	RegionTag      bool
+	Credentials    *credentials.Credentials
 }
 
 type Doc struct {
@@ -59,19 +110,144 @@ type Doc struct {
 }
 
 type Instances struct {
-	Name        string
-	Database    string
-	Type        string
-	Password    string
-	Retention   int
-	Destination string
-	KmsID       string
-	Queries     []Queries
+	Name              string
+	Database          string
+	Type              string
+	Password          string
+	PasswordRef       string `yaml:"password_ref"`
+	RandomPassword    bool   `yaml:"random_password"`
+	IAMAuth           bool   `yaml:"iam_auth"`
+	SSLMode           string `yaml:"sslmode"`
+	Retention         int
+	GFS               GFS      `yaml:"gfs"`
+	MinCopies         int      `yaml:"min_copies"`
+	ForensicRetention int      `yaml:"forensic_retention"`
+	SnapshotTypes     []string `yaml:"snapshot_types"`
+	Manual            Manual
+	SourceRegion      string `yaml:"source_region"`
+	RoleArn           string `yaml:"role_arn"`
+	Destination       string
+	Destinations      []Destinations
+	SkipChecks        bool `yaml:"skip_checks"`
+	MultiDestination  bool `yaml:"-"`
+	KmsID             string
+	Encrypt           bool
+	Vault             Vault
+	Queries           []Queries
+	Golden            Golden
+	Hooks             []Hooks
+	Benchmark         Benchmark
+	Logs              Logs
+	Report            Reports
+	Jobs              []Jobs
+	Sanitize          Sanitize
+	RTO               int
+	PITR              PITR
+	Schedule          Schedule
+	UpgradeTo         string `yaml:"upgrade_to"`
 }
 
 type Queries struct {
+	Query     string
+	Regex     string
+	Golden    string
+	Ignore    []string
+	Tolerance float64
+}
+
+type GFS struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+type Manual struct {
+	Prefix    string
+	Tag       string
+	Retention int
+}
+
+type Destinations struct {
+	Region     string
+	KmsID      string
+	Retention  int
+	GFS        GFS `yaml:"gfs"`
+	Type       string
+	Vault      Vault
+	SkipChecks bool `yaml:"skip_checks"`
+}
+
+type Vault struct {
+	RoleArn string `yaml:"role_arn"`
+	KmsID   string
+}
+
+type Golden struct {
+	Bucket string
+	Prefix string
+}
+
+type Hooks struct {
+	Name    string
+	Command string
+	Args    []string
+	Timeout int
+}
+
+type Logs struct {
+	Fail []string
+	Warn []string
+}
+
+type Reports struct {
+	Bucket  string
+	Prefix  string
+	Queries []ReportQueries
+}
+
+type ReportQueries struct {
+	Name  string
+	Query string
+}
+
+type Jobs struct {
+	Name   string
+	Query  string
+	Format string
+	Bucket string
+	Prefix string
+}
+
+type Sanitize struct {
+	Script    string
+	Accounts  []string
+	Retention int
+}
+
+type Schedule struct {
+	At        []string
+	Window    int
+	Retention int
+}
+
+type PITR struct {
+	Interval         int
+	SubnetIds        []string `yaml:"subnet_ids"`
+	SecurityGroupIds []string `yaml:"security_group_ids"`
+}
+
+type Benchmark struct {
+	Warmup     int
+	Iterations int
+	Baseline   float64
+	Queries    []BenchmarkQueries
+}
+
+type BenchmarkQueries struct {
+	Name  string
 	Query string
-	Regex string
+	P50   float64
+	P95   float64
 }
 
 var Status = map[string]datadog.Status{
@@ -85,11 +261,45 @@ func New() DefaultChecks {
 	return &Client{}
 }
 
-// SetSessions init datadog, RDS and S3 sessions
+// SetSessions init datadog, RDS, S3, Secrets Manager, SSM and KMS sessions
 func (c *Client) SetSessions(region string) {
+	c.setClients(AWSSessions(region), region)
+}
+
+// SetRoleSessions init the sessions with the credentials of roleArn,
+// assumed through STS. Without a role it is the same as SetSessions.
+func (c *Client) SetRoleSessions(region, roleArn string) {
+	if roleArn == "" {
+		c.SetSessions(region)
+		return
+	}
+	sess := AWSSessions(region)
+	sess = sess.Copy(&aws.Config{
+		Credentials: stscreds.NewCredentials(sess, roleArn),
+	})
+	c.setClients(sess, region)
+}
+
+func (c *Client) setClients(sess *session.Session, region string) {
 	c.Datadog = c.DataDogSession(config.DDApiKey, config.DDAplicationKey)
-	c.S3 = s3.New(AWSSessions(region))
-	c.RDS = rds.New(AWSSessions(region))
+	c.S3 = s3.New(sess)
+	c.RDS = rds.New(sess)
+	c.SecretsManager = secretsmanager.New(sess)
+	c.SSM = ssm.New(sess)
+	c.KMS = kms.New(sess)
+	c.Region = region
+	c.RegionTag = false
+	c.Credentials = sess.Config.Credentials
+	if config.DryRun {
+		c.RDS = &dryRunRDS{c.RDS}
+		c.S3 = &dryRunS3{c.S3}
+		c.SecretsManager = &dryRunSecretsManager{c.SecretsManager}
+	}
+}
+
+// SetRegionTag adds the region to the datadog checks and metrics until the sessions change
+func (c *Client) SetRegionTag(enabled bool) {
+	c.RegionTag = enabled
 }
 
 // AWSSessions initiate a new aws session
@@ -121,7 +331,10 @@ func (c *Client) GetYamlFileFromS3(bucket, key string) (io.Reader, error) {
 	return file, nil
 }
 
-// UnmarshalYamlFile unmarshal the yaml file dowmloaded from s3
+// UnmarshalYamlFile unmarshal the yaml file dowmloaded from s3.
+// Values starting with kms: are decrypted with KMS. The passwords are redacted from the logs.
+// The logs patterns are compiled so an invalid one fails loading the file.
+// Instances with several destinations are returned once per destination.
 func (c *Client) UnmarshalYamlFile(body io.Reader) (Doc, error) {
 	doc := Doc{}
 	yamlFile, err := ioutil.ReadAll(body)
@@ -132,16 +345,39 @@ func (c *Client) UnmarshalYamlFile(body io.Reader) (Doc, error) {
 	if err != nil {
 		return Doc{}, err
 	}
-	return doc, nil
+	err = c.decryptValues(reflect.ValueOf(&doc).Elem(), "")
+	if err != nil {
+		return Doc{}, err
+	}
+	err = validateLogPatterns(doc)
+	if err != nil {
+		return Doc{}, err
+	}
+	for _, instance := range doc.Instances {
+		AddSecret(instance.Password)
+	}
+	return expandDestinations(doc), nil
 }
 
 // PostDatadogChecks posts to datadog the status of a check
 func (c *Client) PostDatadogChecks(snapshot *rds.DBSnapshot, metricName, status, cmdName string) error {
+	return c.PostDatadogCheckMessage(snapshot, metricName, status, "", cmdName, nil)
+}
+
+// PostDatadogCheckMessage posts to datadog the status of a check with a message and extra tags
+func (c *Client) PostDatadogCheckMessage(snapshot *rds.DBSnapshot, metricName, status, message, cmdName string, tags []string) error {
+	if config.DryRun {
+		Planned("PostDatadogChecks", metricName+" "+status+" "+*snapshot.DBSnapshotIdentifier)
+		return nil
+	}
 
-	tags := []string{
+	tags = append([]string{
 		"database:" + *snapshot.DBInstanceIdentifier,
 		"snapshot:" + *snapshot.DBSnapshotIdentifier,
 		"command:" + cmdName,
+	}, tags...)
+	if c.RegionTag {
+		tags = append(tags, "region:"+c.Region)
 	}
 
 	timeNow := utils.GetUnixTimeAsString()
@@ -149,7 +385,10 @@ func (c *Client) PostDatadogChecks(snapshot *rds.DBSnapshot, metricName, status,
 	m := datadog.Check{}
 	m.Check = datadog.String(metricName)
 	m.Timestamp = datadog.String(timeNow)
-	m.Tags = tags
+	m.Tags = redactAll(tags)
+	if message != "" {
+		m.Message = datadog.String(Redact(message))
+	}
 
 	if v, ok := Status[status]; ok {
 		s := v
@@ -163,6 +402,38 @@ func (c *Client) PostDatadogChecks(snapshot *rds.DBSnapshot, metricName, status,
 	return nil
 }
 
+// PostDatadogMetric posts a gauge metric to datadog for a snapshot
+func (c *Client) PostDatadogMetric(snapshot *rds.DBSnapshot, metricName string, value float64, cmdName string, tags []string) error {
+	if config.DryRun {
+		Planned("PostDatadogMetric", fmt.Sprintf("%s %v %s", metricName, value, *snapshot.DBSnapshotIdentifier))
+		return nil
+	}
+	tags = append([]string{
+		"database:" + *snapshot.DBInstanceIdentifier,
+		"snapshot:" + *snapshot.DBSnapshotIdentifier,
+		"command:" + cmdName,
+	}, tags...)
+	if c.RegionTag {
+		tags = append(tags, "region:"+c.Region)
+	}
+
+	timeNow := float64(time.Now().Unix())
+
+	m := datadog.Metric{}
+	m.Metric = datadog.String(metricName)
+	m.Type = datadog.String("gauge")
+	m.Points = []datadog.DataPoint{
+		{datadog.Float64(timeNow), datadog.Float64(value)},
+	}
+	m.Tags = redactAll(tags)
+
+	err := c.Datadog.PostMetrics([]datadog.Metric{m})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *Client) CleanArn(snapshot *rds.DBSnapshot) string {
 	arn := strings.SplitN(*snapshot.DBSnapshotArn, ":", 8)
 	cleanArn := arn[len(arn)-1]
@@ -21,11 +21,26 @@ type mockS3 struct {
 	mock.Mock
 }
 
+func (m *mockS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
+}
+
 func (m *mockS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	args := m.Called(input)
 	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
 }
 
+func (m *mockS3) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.CopyObjectOutput), args.Error(1)
+}
+
+func (m *mockS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*s3.DeleteObjectOutput), args.Error(1)
+}
+
 func TestGetYamlFileFromS3(t *testing.T) {
 	s3c := &mockS3{}
 
@@ -65,19 +80,79 @@ func TestUnmarshalYamlFile(t *testing.T) {
 				Password:    "thisisatest",
 				Retention:   1,
 				Destination: "us-east-1",
-				KmsID:       "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456",
+				Logs: Logs{
+					Fail: []string{"PANIC", "invalid page in block"},
+					Warn: []string{"WARNING"},
+				},
+				Report: Reports{
+					Bucket: "rdscheck-reports",
+					Prefix: "reports/",
+					Queries: []ReportQueries{
+						ReportQueries{
+							Name:  "users",
+							Query: "SELECT count(*) FROM users;",
+						},
+					},
+				},
+				Jobs: []Jobs{
+					Jobs{
+						Name:   "orders",
+						Query:  "SELECT * FROM orders WHERE created_at > now() - interval '1 day';",
+						Format: "jsonl",
+						Bucket: "rdscheck-exports",
+						Prefix: "exports/",
+					},
+				},
+				Sanitize: Sanitize{
+					Script:   "UPDATE users SET email = md5(email) || '@example.com';\nUPDATE users SET name = md5(name);\n",
+					Accounts: []string{"123456789012"},
+				},
+				RTO: 60,
+				PITR: PITR{
+					Interval: 24,
+				},
+				UpgradeTo: "12.4",
+				KmsID:     "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456",
+				Encrypt:   true,
 				Queries: []Queries{
 					Queries{
 						Query: "SELECT tablename FROM pg_catalog.pg_tables;",
 						Regex: "^pg_statistic$",
 					},
+					Queries{
+						Query:     "SELECT plan, count(*) AS users, avg(balance) AS balance FROM users GROUP BY plan ORDER BY plan;",
+						Golden:    "users.csv",
+						Tolerance: 0.01,
+					},
+				},
+				Golden: Golden{
+					Bucket: "rdscheck-golden",
+					Prefix: "golden/",
+				},
+				Hooks: []Hooks{
+					Hooks{
+						Command: "/opt/checks/data-quality.py",
+						Args:    []string{"--suite", "nightly"},
+						Timeout: 60,
+					},
+				},
+				Benchmark: Benchmark{
+					Warmup:     2,
+					Iterations: 20,
+					Queries: []BenchmarkQueries{
+						BenchmarkQueries{
+							Query: "SELECT count(*) FROM pg_catalog.pg_tables;",
+							P50:   50,
+							P95:   200,
+						},
+					},
 				},
 			},
 			Instances{
 				Name:        "rdscheck2",
 				Database:    "rdscheck2",
 				Type:        "db.t2.micro",
-				Password:    "thisisatest",
+				PasswordRef: "ssm:/rdscheck/rdscheck2",
 				Retention:   10,
 				Destination: "us-east-2",
 				Queries: []Queries{
@@ -121,6 +196,32 @@ func TestPostDatadogChecks(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestPostDatadogMetric(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://test.local").
+		Post("/v1/series").
+		Reply(202).
+		JSON(map[string]string{"status": "ok"})
+
+	os.Setenv("DATADOG_HOST", "http://test.local")
+	defer os.Unsetenv("DATADOG_HOST")
+
+	dd := datadog.NewClient("", "")
+
+	c := &Client{
+		Datadog: dd,
+	}
+
+	input := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("instance"),
+		DBSnapshotIdentifier: aws.String("test"),
+	}
+
+	err := c.PostDatadogMetric(input, "rdscheck.benchmark.p95", 12.5, "check", []string{"query:tables"})
+	assert.Nil(t, err)
+}
+
 func TestCleanArn(t *testing.T) {
 	c := &Client{}
 
@@ -0,0 +1,54 @@
+package checks
+
+// expandDestinations returns one instance per destination, so the copy and check
+// commands handle each destination like an instance of its own.
+// The settings of a destination override the ones of its instance, and only the
+// first destination keeps the point in time restores, which happen in the source region.
+func expandDestinations(doc Doc) Doc {
+	var instances []Instances
+	for _, instance := range doc.Instances {
+		if len(instance.Destinations) == 0 {
+			instances = append(instances, instance)
+			continue
+		}
+		for i, destination := range instance.Destinations {
+			expanded := instance
+			expanded.Destinations = nil
+			expanded.MultiDestination = true
+			expanded.Destination = destination.Region
+			if destination.KmsID != "" {
+				expanded.KmsID = destination.KmsID
+			}
+			if destination.Retention != 0 {
+				expanded.Retention = destination.Retention
+			}
+			if destination.GFS.Enabled() {
+				expanded.GFS = destination.GFS
+			}
+			if destination.Type != "" {
+				expanded.Type = destination.Type
+			}
+			if destination.Vault.RoleArn != "" {
+				expanded.Vault = destination.Vault
+			}
+			if destination.SkipChecks {
+				expanded.SkipChecks = true
+			}
+			if i > 0 {
+				expanded.PITR = PITR{}
+			}
+			instances = append(instances, expanded)
+		}
+	}
+	doc.Instances = instances
+	return doc
+}
+
+// InstanceKey returns the part of the s3 keys naming an instance in the reports, exports and golden files.
+// The destinations of an instance have their own keys so they don't overwrite each other.
+func InstanceKey(instance Instances) string {
+	if instance.MultiDestination {
+		return instance.Name + "/" + instance.Destination
+	}
+	return instance.Name
+}
@@ -0,0 +1,77 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandDestinations(t *testing.T) {
+	doc := Doc{
+		Instances: []Instances{
+			Instances{
+				Name:      "rdscheck",
+				Type:      "db.t2.micro",
+				Retention: 7,
+				KmsID:     "alias/rdscheck",
+				PITR: PITR{
+					Interval: 24,
+				},
+				Destinations: []Destinations{
+					Destinations{
+						Region: "us-east-1",
+					},
+					Destinations{
+						Region:     "eu-west-1",
+						KmsID:      "alias/rdscheck-eu",
+						Retention:  30,
+						GFS:        GFS{Daily: 7, Monthly: 12},
+						Type:       "db.t3.micro",
+						SkipChecks: true,
+					},
+				},
+			},
+			Instances{
+				Name:        "rdscheck2",
+				Destination: "us-east-2",
+			},
+		},
+	}
+
+	expected := Doc{
+		Instances: []Instances{
+			Instances{
+				Name:             "rdscheck",
+				Type:             "db.t2.micro",
+				Retention:        7,
+				KmsID:            "alias/rdscheck",
+				Destination:      "us-east-1",
+				MultiDestination: true,
+				PITR: PITR{
+					Interval: 24,
+				},
+			},
+			Instances{
+				Name:             "rdscheck",
+				Type:             "db.t3.micro",
+				Retention:        30,
+				GFS:              GFS{Daily: 7, Monthly: 12},
+				KmsID:            "alias/rdscheck-eu",
+				Destination:      "eu-west-1",
+				SkipChecks:       true,
+				MultiDestination: true,
+			},
+			Instances{
+				Name:        "rdscheck2",
+				Destination: "us-east-2",
+			},
+		},
+	}
+
+	assert.Equal(t, expected, expandDestinations(doc))
+}
+
+func TestInstanceKey(t *testing.T) {
+	assert.Equal(t, "rdscheck", InstanceKey(Instances{Name: "rdscheck", Destination: "us-east-1"}))
+	assert.Equal(t, "rdscheck/us-east-1", InstanceKey(Instances{Name: "rdscheck", Destination: "us-east-1", MultiDestination: true}))
+}
@@ -0,0 +1,250 @@
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+// plan holds the calls skipped in dry run mode. It lives as long as the lambda
+// container, so each invocation resets it with ResetPlan.
+var plan = struct {
+	sync.Mutex
+	entries []string
+}{}
+
+// ResetPlan forgets the calls skipped by a previous run
+func ResetPlan() {
+	plan.Lock()
+	defer plan.Unlock()
+	plan.entries = nil
+}
+
+// Plan returns the calls skipped in dry run mode, in order
+func Plan() []string {
+	plan.Lock()
+	defer plan.Unlock()
+	entries := make([]string, len(plan.entries))
+	copy(entries, plan.entries)
+	return entries
+}
+
+// LogPlan logs all the calls skipped by a dry run
+func LogPlan() {
+	if !config.DryRun {
+		return
+	}
+	log.WithFields(log.Fields{
+		"Plan": Plan(),
+	}).Info("Dry run plan")
+}
+
+// Planned records and logs a call skipped in dry run mode
+func Planned(operation string, input interface{}) {
+	entry := operation
+	if input != nil {
+		entry = fmt.Sprintf("%s %s", operation, Redact(fmt.Sprint(input)))
+	}
+	plan.Lock()
+	plan.entries = append(plan.entries, entry)
+	plan.Unlock()
+	log.WithFields(log.Fields{
+		"Operation": operation,
+		"Input":     input,
+	}).Info("Dry run, skipped")
+}
+
+// dryRunRDS only lets the read-only calls through to RDS
+type dryRunRDS struct {
+	rdsiface.RDSAPI
+}
+
+func (d *dryRunRDS) CopyDBSnapshot(input *rds.CopyDBSnapshotInput) (*rds.CopyDBSnapshotOutput, error) {
+	Planned("CopyDBSnapshot", input)
+	return &rds.CopyDBSnapshotOutput{}, nil
+}
+
+func (d *dryRunRDS) DeleteDBSnapshot(input *rds.DeleteDBSnapshotInput) (*rds.DeleteDBSnapshotOutput, error) {
+	Planned("DeleteDBSnapshot", input)
+	return &rds.DeleteDBSnapshotOutput{}, nil
+}
+
+func (d *dryRunRDS) CreateDBSnapshot(input *rds.CreateDBSnapshotInput) (*rds.CreateDBSnapshotOutput, error) {
+	Planned("CreateDBSnapshot", input)
+	return &rds.CreateDBSnapshotOutput{}, nil
+}
+
+func (d *dryRunRDS) ModifyDBSnapshotAttribute(input *rds.ModifyDBSnapshotAttributeInput) (*rds.ModifyDBSnapshotAttributeOutput, error) {
+	Planned("ModifyDBSnapshotAttribute", input)
+	return &rds.ModifyDBSnapshotAttributeOutput{}, nil
+}
+
+func (d *dryRunRDS) CreateDBSubnetGroup(input *rds.CreateDBSubnetGroupInput) (*rds.CreateDBSubnetGroupOutput, error) {
+	Planned("CreateDBSubnetGroup", input)
+	return &rds.CreateDBSubnetGroupOutput{
+		DBSubnetGroup: &rds.DBSubnetGroup{
+			DBSubnetGroupArn:  aws.String("dry-run"),
+			DBSubnetGroupName: input.DBSubnetGroupName,
+		},
+	}, nil
+}
+
+func (d *dryRunRDS) DeleteDBSubnetGroup(input *rds.DeleteDBSubnetGroupInput) (*rds.DeleteDBSubnetGroupOutput, error) {
+	Planned("DeleteDBSubnetGroup", input)
+	return &rds.DeleteDBSubnetGroupOutput{}, nil
+}
+
+func (d *dryRunRDS) RestoreDBInstanceFromDBSnapshot(input *rds.RestoreDBInstanceFromDBSnapshotInput) (*rds.RestoreDBInstanceFromDBSnapshotOutput, error) {
+	Planned("RestoreDBInstanceFromDBSnapshot", input)
+	return &rds.RestoreDBInstanceFromDBSnapshotOutput{}, nil
+}
+
+func (d *dryRunRDS) RestoreDBInstanceToPointInTime(input *rds.RestoreDBInstanceToPointInTimeInput) (*rds.RestoreDBInstanceToPointInTimeOutput, error) {
+	Planned("RestoreDBInstanceToPointInTime", input)
+	return &rds.RestoreDBInstanceToPointInTimeOutput{}, nil
+}
+
+func (d *dryRunRDS) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	Planned("ModifyDBInstance", input)
+	return &rds.ModifyDBInstanceOutput{}, nil
+}
+
+func (d *dryRunRDS) DeleteDBInstance(input *rds.DeleteDBInstanceInput) (*rds.DeleteDBInstanceOutput, error) {
+	Planned("DeleteDBInstance", input)
+	return &rds.DeleteDBInstanceOutput{}, nil
+}
+
+func (d *dryRunRDS) AddTagsToResource(input *rds.AddTagsToResourceInput) (*rds.AddTagsToResourceOutput, error) {
+	Planned("AddTagsToResource", input)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+func (d *dryRunRDS) RemoveTagsFromResource(input *rds.RemoveTagsFromResourceInput) (*rds.RemoveTagsFromResourceOutput, error) {
+	Planned("RemoveTagsFromResource", input)
+	return &rds.RemoveTagsFromResourceOutput{}, nil
+}
+
+// dryRunS3 only lets the read-only calls through to S3.
+// The multipart calls are stubbed too since the s3manager uploader of ExportQuery uses them.
+type dryRunS3 struct {
+	s3iface.S3API
+}
+
+func (d *dryRunS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	Planned("PutObject", "s3://"+aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key))
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (d *dryRunS3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return d.PutObject(input)
+}
+
+// PutObjectRequest is used by the s3manager uploader for the small uploads.
+// The request is built as usual but never sent.
+func (d *dryRunS3) PutObjectRequest(input *s3.PutObjectInput) (*request.Request, *s3.PutObjectOutput) {
+	req, out := d.S3API.PutObjectRequest(input)
+	req.Handlers.Send.Clear()
+	req.Handlers.Send.PushBack(func(r *request.Request) {
+		Planned("PutObject", "s3://"+aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key))
+		r.HTTPResponse = &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}
+	})
+	return req, out
+}
+
+func (d *dryRunS3) CreateMultipartUpload(input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error) {
+	Planned("CreateMultipartUpload", "s3://"+aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key))
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   input.Bucket,
+		Key:      input.Key,
+		UploadId: aws.String("dry-run"),
+	}, nil
+}
+
+func (d *dryRunS3) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return d.CreateMultipartUpload(input)
+}
+
+// UploadPart isn't planned, the upload is planned once by CreateMultipartUpload
+func (d *dryRunS3) UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+	return &s3.UploadPartOutput{
+		ETag: aws.String("dry-run"),
+	}, nil
+}
+
+func (d *dryRunS3) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	return d.UploadPart(input)
+}
+
+func (d *dryRunS3) CompleteMultipartUpload(input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error) {
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket: input.Bucket,
+		Key:    input.Key,
+	}, nil
+}
+
+func (d *dryRunS3) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	return d.CompleteMultipartUpload(input)
+}
+
+func (d *dryRunS3) AbortMultipartUpload(input *s3.AbortMultipartUploadInput) (*s3.AbortMultipartUploadOutput, error) {
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (d *dryRunS3) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return d.AbortMultipartUpload(input)
+}
+
+func (d *dryRunS3) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	return d.CopyObject(input)
+}
+
+func (d *dryRunS3) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	return d.DeleteObject(input)
+}
+
+func (d *dryRunS3) CopyObject(input *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	Planned("CopyObject", input)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (d *dryRunS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	Planned("DeleteObject", input)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// dryRunSecretsManager only lets the read-only calls through to Secrets Manager
+type dryRunSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+}
+
+func (d *dryRunSecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	Planned("CreateSecret", aws.StringValue(input.Name))
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (d *dryRunSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	Planned("PutSecretValue", aws.StringValue(input.SecretId))
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (d *dryRunSecretsManager) DeleteSecret(input *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	Planned("DeleteSecret", input)
+	return &secretsmanager.DeleteSecretOutput{}, nil
+}
@@ -0,0 +1,205 @@
+package checks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+func TestDryRunRDS(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: &dryRunRDS{rdsc},
+	}
+
+	rdsc.On("DescribeDBSnapshots", mock.Anything).Return(&rds.DescribeDBSnapshotsOutput{
+		DBSnapshots: []*rds.DBSnapshot{
+			&rds.DBSnapshot{
+				Status:             aws.String("available"),
+				SnapshotCreateTime: aws.Time(time.Now()),
+			},
+		},
+	}, nil)
+
+	snapshots, err := c.GetSnapshots("test")
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+
+	snapshot := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	err = c.DeleteOldSnapshot(snapshot)
+	assert.Nil(t, err)
+	err = c.UpdateTag(snapshot, "Status", "restore")
+	assert.Nil(t, err)
+	err = c.DeleteDB(snapshot)
+	assert.Nil(t, err)
+
+	assert.Len(t, Plan(), 4)
+	assert.Contains(t, Plan()[0], "DeleteDBSnapshot")
+	assert.Contains(t, Plan()[1], "RemoveTagsFromResource")
+	assert.Contains(t, Plan()[2], "AddTagsToResource")
+	assert.Contains(t, Plan()[3], "DeleteDBInstance")
+	rdsc.AssertExpectations(t)
+	rdsc.AssertNotCalled(t, "DeleteDBSnapshot", mock.Anything)
+}
+
+func TestDryRunS3(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+
+	s3c := &mockS3{}
+
+	c := &Client{
+		S3: &dryRunS3{s3c},
+	}
+
+	_, err := c.S3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("rdscheck-reports"),
+		Key:    aws.String("reports/test.json"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PutObject s3://rdscheck-reports/reports/test.json"}, Plan())
+	s3c.AssertNotCalled(t, "PutObject", mock.Anything)
+}
+
+func TestDryRunDatadog(t *testing.T) {
+	ResetPlan()
+	config.DryRun = true
+	defer func() {
+		ResetPlan()
+		config.DryRun = false
+	}()
+
+	c := &Client{}
+
+	snapshot := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("instance"),
+		DBSnapshotIdentifier: aws.String("test"),
+	}
+
+	err := c.PostDatadogChecks(snapshot, "rdscheck.status", "ok", "copy")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PostDatadogChecks rdscheck.status ok test"}, Plan())
+}
+
+func TestResetPlan(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Planned("DeleteDBSnapshot", nil)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, Plan(), 10)
+
+	ResetPlan()
+	assert.Empty(t, Plan())
+}
+
+func TestDryRunChangeDBpassword(t *testing.T) {
+	ResetPlan()
+	config.DryRun = true
+	defer func() {
+		ResetPlan()
+		config.DryRun = false
+	}()
+
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: &dryRunRDS{rdsc},
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	err := c.ChangeDBpassword(input, "arn:aws:rds:us-west-2:123456789012:database:test", "password")
+	assert.Nil(t, err)
+	assert.Len(t, Plan(), 1)
+	assert.Contains(t, Plan()[0], "ModifyDBInstance")
+	rdsc.AssertNotCalled(t, "DescribeDBInstances", mock.Anything)
+}
+
+func TestDryRunS3Uploader(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+
+	// every request reaching this server is a write that escaped the dry run
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	c := &Client{
+		S3: &dryRunS3{s3.New(sess)},
+	}
+
+	uploader := s3manager.NewUploaderWithClient(c.S3)
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String("rdscheck-exports"),
+		Key:    aws.String("test/small.csv"),
+		Body:   strings.NewReader("id\n1\n"),
+	})
+	assert.Nil(t, err)
+
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String("rdscheck-exports"),
+		Key:    aws.String("test/large.csv"),
+		Body:   io.LimitReader(zeros{}, 2*s3manager.MinUploadPartSize+1),
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+	assert.Equal(t, []string{
+		"PutObject s3://rdscheck-exports/test/small.csv",
+		"CreateMultipartUpload s3://rdscheck-exports/test/large.csv",
+	}, Plan())
+}
+
+// zeros is an endless reader of zeros, used as a large upload body
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
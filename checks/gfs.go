@@ -0,0 +1,84 @@
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// Enabled tells if the grandfather-father-son retention is set
+func (g GFS) Enabled() bool {
+	return g.Daily > 0 || g.Weekly > 0 || g.Monthly > 0
+}
+
+// GetGFSOldSnapshots gets old snapshots based on a grandfather-father-son retention policy.
+// The newest snapshot of each of the last gfs.Daily days, gfs.Weekly weeks and gfs.Monthly months
+// are kept, based on their creation time in UTC. Days and weeks without snapshots are not counted.
+// The copies are bucketed by the creation time of their source, like the sources themselves.
+func (c *Client) GetGFSOldSnapshots(snapshots []*rds.DBSnapshot, gfs GFS) ([]*rds.DBSnapshot, error) {
+	var available []*rds.DBSnapshot
+	created := map[*rds.DBSnapshot]time.Time{}
+	for _, s := range snapshots {
+		if *s.Status == "available" {
+			available = append(available, s)
+			created[s] = c.gfsTime(s).UTC()
+		}
+	}
+
+	sorted := make([]*rds.DBSnapshot, len(available))
+	copy(sorted, available)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return created[sorted[i]].After(created[sorted[j]])
+	})
+
+	keep := map[*rds.DBSnapshot]bool{}
+	periods := []struct {
+		count  int
+		bucket func(s *rds.DBSnapshot) string
+	}{
+		{gfs.Daily, func(s *rds.DBSnapshot) string {
+			return created[s].Format("2006-01-02")
+		}},
+		{gfs.Weekly, func(s *rds.DBSnapshot) string {
+			year, week := created[s].ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{gfs.Monthly, func(s *rds.DBSnapshot) string {
+			return created[s].Format("2006-01")
+		}},
+	}
+	for _, period := range periods {
+		seen := map[string]bool{}
+		for _, s := range sorted {
+			if len(seen) >= period.count {
+				break
+			}
+			bucket := period.bucket(s)
+			if seen[bucket] {
+				continue
+			}
+			seen[bucket] = true
+			keep[s] = true
+		}
+	}
+
+	var oldSnapshots []*rds.DBSnapshot
+	for _, s := range available {
+		if !keep[s] {
+			oldSnapshots = append(oldSnapshots, s)
+		}
+	}
+	return oldSnapshots, nil
+}
+
+// gfsTime is the time a snapshot is bucketed by. A copy is created when the copy job runs,
+// so its SourceCreateTime tag is used when it has one.
+func (c *Client) gfsTime(s *rds.DBSnapshot) time.Time {
+	t, err := time.Parse(time.RFC3339, c.GetTagValue(*s.DBSnapshotArn, "SourceCreateTime"))
+	if err != nil {
+		return *s.SnapshotCreateTime
+	}
+	return t
+}
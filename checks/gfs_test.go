@@ -0,0 +1,135 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetGFSOldSnapshots(t *testing.T) {
+	rdsc := &mockRDS{}
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("ListTagsForResource", mock.Anything).Return(&rds.ListTagsForResourceOutput{}, nil)
+
+	// one snapshot a day for 100 days, oldest first like GetSnapshots
+	end := time.Date(2020, 6, 30, 3, 0, 0, 0, time.UTC)
+	var snapshots []*rds.DBSnapshot
+	for i := 99; i >= 0; i-- {
+		snapshots = append(snapshots, &rds.DBSnapshot{
+			Status:               aws.String("available"),
+			DBSnapshotIdentifier: aws.String(end.AddDate(0, 0, -i).Format("2006-01-02")),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:" + end.AddDate(0, 0, -i).Format("2006-01-02")),
+			SnapshotCreateTime:   aws.Time(end.AddDate(0, 0, -i)),
+		})
+	}
+
+	old, err := c.GetGFSOldSnapshots(snapshots, GFS{Daily: 7, Weekly: 4, Monthly: 3})
+	assert.Nil(t, err)
+
+	deleted := map[string]bool{}
+	for _, s := range old {
+		deleted[*s.DBSnapshotIdentifier] = true
+	}
+	var kept []string
+	for _, s := range snapshots {
+		if !deleted[*s.DBSnapshotIdentifier] {
+			kept = append(kept, *s.DBSnapshotIdentifier)
+		}
+	}
+
+	assert.Equal(t, []string{
+		// monthly
+		"2020-04-30",
+		"2020-05-31",
+		// weekly
+		"2020-06-14",
+		"2020-06-21",
+		// daily, the newest also being the last weekly and monthly ones
+		"2020-06-24", "2020-06-25", "2020-06-26", "2020-06-27", "2020-06-28", "2020-06-29", "2020-06-30",
+	}, kept)
+}
+
+func TestGetGFSOldSnapshotsSkipsUnavailable(t *testing.T) {
+	rdsc := &mockRDS{}
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("ListTagsForResource", mock.Anything).Return(&rds.ListTagsForResourceOutput{}, nil)
+
+	snapshots := []*rds.DBSnapshot{
+		&rds.DBSnapshot{
+			Status:               aws.String("creating"),
+			DBSnapshotIdentifier: aws.String("creating"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:creating"),
+			SnapshotCreateTime:   aws.Time(time.Now().AddDate(-1, 0, 0)),
+		},
+		&rds.DBSnapshot{
+			Status:               aws.String("available"),
+			DBSnapshotIdentifier: aws.String("old"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:old"),
+			SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -2)),
+		},
+		&rds.DBSnapshot{
+			Status:               aws.String("available"),
+			DBSnapshotIdentifier: aws.String("new"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:new"),
+			SnapshotCreateTime:   aws.Time(time.Now()),
+		},
+	}
+
+	old, err := c.GetGFSOldSnapshots(snapshots, GFS{Daily: 1})
+	assert.Nil(t, err)
+	assert.Len(t, old, 1)
+	assert.Equal(t, "old", *old[0].DBSnapshotIdentifier)
+}
+
+func TestGetGFSOldSnapshotsSourceCreateTime(t *testing.T) {
+	rdsc := &mockRDS{}
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	// both copies were created today, but the source of "recopied" is from 3 days ago,
+	// so it must not take the daily bucket of "today"
+	snapshots := []*rds.DBSnapshot{
+		&rds.DBSnapshot{
+			Status:               aws.String("available"),
+			DBSnapshotIdentifier: aws.String("today"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:today"),
+			SnapshotCreateTime:   aws.Time(time.Now().Add(-time.Hour)),
+		},
+		&rds.DBSnapshot{
+			Status:               aws.String("available"),
+			DBSnapshotIdentifier: aws.String("recopied"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:recopied"),
+			SnapshotCreateTime:   aws.Time(time.Now()),
+		},
+	}
+
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{
+		ResourceName: aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:today"),
+	}).Return(&rds.ListTagsForResourceOutput{}, nil)
+	rdsc.On("ListTagsForResource", &rds.ListTagsForResourceInput{
+		ResourceName: aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:recopied"),
+	}).Return(&rds.ListTagsForResourceOutput{
+		TagList: []*rds.Tag{
+			{
+				Key:   aws.String("SourceCreateTime"),
+				Value: aws.String(time.Now().AddDate(0, 0, -3).UTC().Format(time.RFC3339)),
+			},
+		},
+	}, nil)
+
+	old, err := c.GetGFSOldSnapshots(snapshots, GFS{Daily: 1})
+	assert.Nil(t, err)
+	assert.Len(t, old, 1)
+	assert.Equal(t, "recopied", *old[0].DBSnapshotIdentifier)
+}
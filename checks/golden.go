@@ -0,0 +1,256 @@
+package checks
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxGoldenDiff is the maximum number of differences we report for a query
+const maxGoldenDiff = 20
+
+// actualSuffix is appended to the golden file key to store the result of a query that didn't match
+const actualSuffix = ".actual"
+
+// GoldenKey returns the s3 key of a golden file
+func GoldenKey(golden Golden, instance, file string) string {
+	return golden.Prefix + instance + "/" + file
+}
+
+// CompareGolden runs a query and compares its full result with the golden file stored in s3.
+// It returns the differences, or nothing if the result matches.
+// When the result doesn't match, it is stored next to the golden file so it can be accepted later.
+func (c *Client) CompareGolden(query Queries, bucket, key string) ([]string, error) {
+	rows, err := c.DB.Query(query.Query)
+	if err != nil {
+		return nil, err
+	}
+	cols, actual, err := scanRows(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var diff []string
+
+	o, err := c.S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != s3.ErrCodeNoSuchKey {
+			return nil, err
+		}
+		diff = []string{"golden file " + key + " does not exist"}
+	} else {
+		body, err := ioutil.ReadAll(o.Body)
+		o.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		expected, err := parseGolden(key, body, cols)
+		if err != nil {
+			return nil, err
+		}
+		diff = compareRows(cols, expected, actual, query.Ignore, query.Tolerance)
+	}
+
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	body, err := formatGolden(key, cols, actual)
+	if err != nil {
+		return diff, err
+	}
+	_, err = c.S3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key + actualSuffix),
+		Body:   bytes.NewReader(body),
+	})
+	if err != nil {
+		return diff, err
+	}
+	return diff, nil
+}
+
+// AcceptGolden replaces a golden file with the last result that didn't match it.
+// The copy source has to be url encoded since golden files can have any name.
+func (c *Client) AcceptGolden(bucket, key string) error {
+	_, err := c.S3.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		CopySource: aws.String((&url.URL{Path: bucket + "/" + key + actualSuffix}).EscapedPath()),
+		Key:        aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			log.WithFields(log.Fields{
+				"Bucket": bucket,
+				"Key":    key,
+			}).Info("Nothing to accept")
+			return nil
+		}
+		return err
+	}
+
+	_, err = c.S3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key + actualSuffix),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Bucket": bucket,
+		"Key":    key,
+	}).Info("Golden file accepted")
+	return nil
+}
+
+// parseGolden reads a csv (with a header line) or json (an array of objects) golden file
+// and returns its rows with the values in the same order as cols
+func parseGolden(key string, body []byte, cols []string) ([][]string, error) {
+	var records []map[string]string
+
+	switch path.Ext(key) {
+	case ".csv":
+		lines, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			return nil, nil
+		}
+		for _, line := range lines[1:] {
+			record := make(map[string]string, len(line))
+			for i, value := range line {
+				if i < len(lines[0]) {
+					record[lines[0][i]] = value
+				}
+			}
+			records = append(records, record)
+		}
+	case ".json":
+		var objects []map[string]interface{}
+		err := json.Unmarshal(body, &objects)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			record := make(map[string]string, len(object))
+			for name, value := range object {
+				switch v := value.(type) {
+				case nil:
+					record[name] = ""
+				case string:
+					record[name] = v
+				case float64:
+					record[name] = strconv.FormatFloat(v, 'f', -1, 64)
+				default:
+					record[name] = fmt.Sprint(v)
+				}
+			}
+			records = append(records, record)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported golden file format %q", path.Ext(key))
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		row := make([]string, len(cols))
+		for i, col := range cols {
+			row[i] = record[col]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// formatGolden writes rows in the format of the golden file
+func formatGolden(key string, cols []string, rows [][]string) ([]byte, error) {
+	if path.Ext(key) == ".json" {
+		objects := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			object := make(map[string]string, len(cols))
+			for i, col := range cols {
+				object[col] = row[i]
+			}
+			objects = append(objects, object)
+		}
+		return json.MarshalIndent(objects, "", "  ")
+	}
+
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	err := writer.Write(cols)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compareRows compares the rows one by one and returns a readable list of differences.
+// Numeric values are equal if they are within tolerance of each others.
+func compareRows(cols []string, expected, actual [][]string, ignore []string, tolerance float64) []string {
+	ignored := make(map[string]bool, len(ignore))
+	for _, col := range ignore {
+		ignored[col] = true
+	}
+
+	var diff []string
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if len(diff) >= maxGoldenDiff {
+			return append(diff, "...")
+		}
+		if i >= len(actual) {
+			diff = append(diff, fmt.Sprintf("row %d: missing %v", i+1, expected[i]))
+			continue
+		}
+		if i >= len(expected) {
+			diff = append(diff, fmt.Sprintf("row %d: unexpected %v", i+1, actual[i]))
+			continue
+		}
+		for j, col := range cols {
+			if ignored[col] || equalValues(expected[i][j], actual[i][j], tolerance) {
+				continue
+			}
+			diff = append(diff, fmt.Sprintf("row %d, column %s: expected %q, got %q", i+1, col, expected[i][j], actual[i][j]))
+		}
+	}
+	return diff
+}
+
+// equalValues returns true if both values are the same string or numbers within tolerance
+func equalValues(expected, actual string, tolerance float64) bool {
+	if expected == actual {
+		return true
+	}
+	e, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false
+	}
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	return math.Abs(e-a) <= tolerance
+}
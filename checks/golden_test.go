@@ -0,0 +1,187 @@
+package checks
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGoldenKey(t *testing.T) {
+	golden := Golden{
+		Bucket: "golden",
+		Prefix: "golden/",
+	}
+
+	assert.Equal(t, GoldenKey(golden, "instance", "users.csv"), "golden/instance/users.csv")
+}
+
+func TestCompareGolden(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mockS3 := &mockS3{}
+	c := &Client{
+		DB: db,
+		S3: mockS3,
+	}
+
+	query := Queries{
+		Query:     "SELECT id, total, updated_at FROM orders",
+		Ignore:    []string{"updated_at"},
+		Tolerance: 0.01,
+	}
+
+	mockdb.ExpectQuery("SELECT id, total, updated_at FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id", "total", "updated_at"}).
+		AddRow(int64(1), 10.001, "2020-01-02").
+		AddRow(int64(2), 20.5, "2020-01-02"))
+
+	mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+		Body: ioutil.NopCloser(bytes.NewReader([]byte("id,total,updated_at\n1,10,2020-01-01\n2,20.5,2020-01-01\n"))),
+	}, nil)
+
+	diff, err := c.CompareGolden(query, "golden", "instance/orders.csv")
+	assert.Nil(t, err)
+	assert.Empty(t, diff)
+	mockS3.AssertNotCalled(t, "PutObject", mock.Anything)
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
+
+func TestCompareGoldenMismatch(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mockS3 := &mockS3{}
+	c := &Client{
+		DB: db,
+		S3: mockS3,
+	}
+
+	query := Queries{
+		Query: "SELECT id, email FROM users",
+	}
+
+	mockdb.ExpectQuery("SELECT id, email FROM users").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).
+		AddRow(int64(1), "new@example.com"))
+
+	mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+		Body: ioutil.NopCloser(bytes.NewReader([]byte(`[{"id": 1, "email": "test@example.com"}, {"id": 2, "email": null}]`))),
+	}, nil)
+	mockS3.On("PutObject", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Key == "instance/users.json.actual"
+	})).Return(&s3.PutObjectOutput{}, nil)
+
+	diff, err := c.CompareGolden(query, "golden", "instance/users.json")
+	assert.Nil(t, err)
+	assert.Equal(t, diff, []string{
+		"row 1, column email: expected \"test@example.com\", got \"new@example.com\"",
+		"row 2: missing [2 ]",
+	})
+	mockS3.AssertExpectations(t)
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
+
+func TestCompareGoldenMissing(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mockS3 := &mockS3{}
+	c := &Client{
+		DB: db,
+		S3: mockS3,
+	}
+
+	query := Queries{
+		Query: "SELECT id FROM users",
+	}
+
+	mockdb.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+
+	mockS3.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{}, awserr.New(s3.ErrCodeNoSuchKey, "", nil))
+	mockS3.On("PutObject", mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+	diff, err := c.CompareGolden(query, "golden", "instance/users.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, diff, []string{"golden file instance/users.csv does not exist"})
+	mockS3.AssertExpectations(t)
+}
+
+func TestAcceptGolden(t *testing.T) {
+	mockS3 := &mockS3{}
+	c := &Client{
+		S3: mockS3,
+	}
+
+	mockS3.On("CopyObject", &s3.CopyObjectInput{
+		Bucket:     aws.String("golden"),
+		CopySource: aws.String("golden/instance/users.csv.actual"),
+		Key:        aws.String("instance/users.csv"),
+	}).Return(&s3.CopyObjectOutput{}, nil)
+	mockS3.On("DeleteObject", &s3.DeleteObjectInput{
+		Bucket: aws.String("golden"),
+		Key:    aws.String("instance/users.csv.actual"),
+	}).Return(&s3.DeleteObjectOutput{}, nil)
+
+	err := c.AcceptGolden("golden", "instance/users.csv")
+	assert.Nil(t, err)
+	mockS3.AssertExpectations(t)
+}
+
+func TestAcceptGoldenEncoded(t *testing.T) {
+	mockS3 := &mockS3{}
+	c := &Client{
+		S3: mockS3,
+	}
+
+	mockS3.On("CopyObject", &s3.CopyObjectInput{
+		Bucket:     aws.String("golden"),
+		CopySource: aws.String("golden/instance/monthly%20totals%25.csv.actual"),
+		Key:        aws.String("instance/monthly totals%.csv"),
+	}).Return(&s3.CopyObjectOutput{}, nil)
+	mockS3.On("DeleteObject", mock.Anything).Return(&s3.DeleteObjectOutput{}, nil)
+
+	err := c.AcceptGolden("golden", "instance/monthly totals%.csv")
+	assert.Nil(t, err)
+	mockS3.AssertExpectations(t)
+}
+
+func TestAcceptGoldenNothing(t *testing.T) {
+	mockS3 := &mockS3{}
+	c := &Client{
+		S3: mockS3,
+	}
+
+	mockS3.On("CopyObject", mock.Anything).Return(&s3.CopyObjectOutput{}, awserr.New(s3.ErrCodeNoSuchKey, "", nil))
+
+	err := c.AcceptGolden("golden", "instance/users.csv")
+	assert.Nil(t, err)
+	mockS3.AssertNotCalled(t, "DeleteObject", mock.Anything)
+}
+
+func TestCompareRows(t *testing.T) {
+	cols := []string{"id", "total"}
+	expected := [][]string{{"1", "10"}, {"2", "20"}}
+	actual := [][]string{{"1", "10.5"}, {"2", "20"}, {"3", "30"}}
+
+	assert.Equal(t, compareRows(cols, expected, actual, nil, 1), []string{"row 3: unexpected [3 30]"})
+	assert.Equal(t, compareRows(cols, expected, actual, []string{"total"}, 0), []string{"row 3: unexpected [3 30]"})
+	assert.Equal(t, compareRows(cols, expected, actual, nil, 0), []string{
+		"row 1, column total: expected \"10\", got \"10.5\"",
+		"row 3: unexpected [3 30]",
+	})
+}
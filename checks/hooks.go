@@ -0,0 +1,102 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+// defaultHookTimeout is used when a hook doesn't define its own timeout (in seconds)
+const defaultHookTimeout = 30
+
+// hookMessageLength is the maximum length of the output sent to datadog
+const hookMessageLength = 1000
+
+// HookResult is what an external hook command returned
+type HookResult struct {
+	ExitCode int
+	Output   string
+	Stderr   string
+}
+
+// HookName returns the name of a hook, the base name of its command if it doesn't have one
+func HookName(hook Hooks) string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+	return filepath.Base(hook.Command)
+}
+
+// Message returns the exit code and the output of a hook, truncated for a datadog check
+func (r HookResult) Message() string {
+	message := "exit code " + strconv.Itoa(r.ExitCode)
+	if r.Output != "" {
+		message += "\nstdout:\n" + r.Output
+	}
+	if r.Stderr != "" {
+		message += "\nstderr:\n" + r.Stderr
+	}
+	if len(message) > hookMessageLength {
+		message = message[:hookMessageLength] + "..."
+	}
+	return message
+}
+
+// RunHook runs an external command against the restored database.
+// The connection informations are passed to the command as environment variables.
+// A non zero exit code or a timeout will return an error.
+func (c *Client) RunHook(db *rds.DBInstance, password, dbname, sslmode string, hook Hooks) (HookResult, error) {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Env = append(os.Environ(),
+		"RDSCHECK_HOST="+*db.Endpoint.Address,
+		"RDSCHECK_PORT="+strconv.FormatInt(*db.Endpoint.Port, 10),
+		"RDSCHECK_USER="+*db.MasterUsername,
+		"RDSCHECK_PASSWORD="+password,
+		"RDSCHECK_DATABASE="+dbname,
+		"RDSCHECK_SSLMODE="+sslmode,
+		"RDSCHECK_SSLROOTCERT="+config.RDSCABundle,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := HookResult{
+		ExitCode: -1,
+		Output:   stdout.String(),
+		Stderr:   stderr.String(),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		log.WithFields(log.Fields{
+			"Command": hook.Command,
+			"Timeout": timeout,
+		}).Error("Hook timed out")
+		return result, ctx.Err()
+	}
+
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
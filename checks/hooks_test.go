@@ -0,0 +1,75 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+var hookInstance = &rds.DBInstance{
+	Endpoint: &rds.Endpoint{
+		Address: aws.String("localhost"),
+		Port:    aws.Int64(5432),
+	},
+	MasterUsername: aws.String("rdscheck"),
+}
+
+func TestRunHookSuccess(t *testing.T) {
+	c := &Client{}
+
+	hook := Hooks{
+		Command: "sh",
+		Args:    []string{"-c", "echo $RDSCHECK_HOST:$RDSCHECK_PORT/$RDSCHECK_DATABASE"},
+	}
+
+	value, err := c.RunHook(hookInstance, "password", "test", "verify-full", hook)
+	assert.Nil(t, err)
+	assert.Equal(t, value.ExitCode, 0)
+	assert.Equal(t, value.Output, "localhost:5432/test\n")
+}
+
+func TestRunHookFailure(t *testing.T) {
+	c := &Client{}
+
+	hook := Hooks{
+		Command: "sh",
+		Args:    []string{"-c", "echo failed; echo missing rows >&2; exit 2"},
+	}
+
+	value, err := c.RunHook(hookInstance, "password", "test", "verify-full", hook)
+	assert.NotNil(t, err)
+	assert.Equal(t, value.ExitCode, 2)
+	assert.Equal(t, value.Output, "failed\n")
+	assert.Equal(t, value.Stderr, "missing rows\n")
+	assert.Equal(t, "exit code 2\nstdout:\nfailed\n\nstderr:\nmissing rows\n", value.Message())
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	c := &Client{}
+
+	hook := Hooks{
+		Command: "sleep",
+		Args:    []string{"5"},
+		Timeout: 1,
+	}
+
+	_, err := c.RunHook(hookInstance, "password", "test", "verify-full", hook)
+	assert.NotNil(t, err)
+}
+
+func TestHookResultMessageTruncated(t *testing.T) {
+	result := HookResult{
+		ExitCode: 1,
+		Output:   strings.Repeat("a", 2*hookMessageLength),
+	}
+
+	assert.Equal(t, hookMessageLength+3, len(result.Message()))
+}
+
+func TestHookName(t *testing.T) {
+	assert.Equal(t, "data-quality.py", HookName(Hooks{Command: "/opt/checks/data-quality.py"}))
+	assert.Equal(t, "quality", HookName(Hooks{Name: "quality", Command: "/opt/checks/data-quality.py"}))
+}
@@ -0,0 +1,20 @@
+package checks
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+)
+
+// BuildAuthToken generates an IAM authentication token for the master user of a restored instance.
+// The token is used as the password and is valid for 15 minutes.
+// It is a presigned url, so its signature is redacted from the logs without registering it.
+func (c *Client) BuildAuthToken(db *rds.DBInstance) (string, error) {
+	endpoint := *db.Endpoint.Address + ":" + strconv.FormatInt(*db.Endpoint.Port, 10)
+	token, err := rdsutils.BuildAuthToken(endpoint, c.Region, *db.MasterUsername, c.Credentials)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -0,0 +1,39 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAuthToken(t *testing.T) {
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}
+
+	db := &rds.DBInstance{
+		Endpoint: &rds.Endpoint{
+			Address: aws.String("test.rds.amazonaws.com"),
+			Port:    aws.Int64(5432),
+		},
+		MasterUsername: aws.String("postgres"),
+	}
+
+	value, err := c.BuildAuthToken(db)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(value, "test.rds.amazonaws.com:5432?"))
+	assert.Contains(t, value, "Action=connect")
+	assert.Contains(t, value, "DBUser=postgres")
+	assert.Contains(t, value, "X-Amz-Credential=AKID")
+
+	secrets.RLock()
+	registered := append([]string{}, secrets.values...)
+	secrets.RUnlock()
+	assert.NotContains(t, registered, value)
+	assert.Contains(t, Redact(value), "X-Amz-Signature="+redacted)
+}
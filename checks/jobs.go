@@ -0,0 +1,156 @@
+package checks
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	log "github.com/sirupsen/logrus"
+)
+
+// exportFormats are the file extensions of the supported export formats
+var exportFormats = map[string]string{
+	"csv":   "csv",
+	"jsonl": "jsonl",
+}
+
+// ExportKey returns the s3 key where the result of a job is written for a snapshot
+func ExportKey(job Jobs, instance, snapshot string) string {
+	format := job.Format
+	if format == "" {
+		format = "csv"
+	}
+	return job.Prefix + instance + "/" + snapshot + "/" + job.Name + "." + exportFormats[format]
+}
+
+// ExportQuery streams the full result of a query to s3 as csv or json lines
+// and returns how many rows were exported
+func (c *Client) ExportQuery(query, format, bucket, key string) (int64, error) {
+	if format == "" {
+		format = "csv"
+	}
+	if _, ok := exportFormats[format]; !ok {
+		return 0, fmt.Errorf("Unsupported export format %q", format)
+	}
+
+	rows, err := c.DB.Query(query)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	pr, pw := io.Pipe()
+	go func() {
+		defer rows.Close()
+		var err error
+		if format == "jsonl" {
+			count, err = writeJSONLines(rows, pw)
+		} else {
+			count, err = writeCSV(rows, pw)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	uploader := s3manager.NewUploaderWithClient(c.S3)
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   pr,
+	})
+	if err != nil {
+		pr.CloseWithError(err)
+		return 0, err
+	}
+
+	log.WithFields(log.Fields{
+		"Bucket": bucket,
+		"Key":    key,
+		"Rows":   count,
+	}).Info("Query exported")
+	return count, nil
+}
+
+// writeCSV writes the columns names and then every row as csv. NULL values are written as empty strings.
+func writeCSV(rows *sql.Rows, w io.Writer) (int64, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	writer := csv.NewWriter(w)
+	err = writer.Write(cols)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for rows.Next() {
+		columns, err := scanNullStrings(rows, len(cols))
+		if err != nil {
+			return count, err
+		}
+
+		record := make([]string, len(cols))
+		for i, column := range columns {
+			record[i] = column.String
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return count, err
+	}
+	return count, rows.Err()
+}
+
+// writeJSONLines writes every row as a json object on its own line
+func writeJSONLines(rows *sql.Rows, w io.Writer) (int64, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(w)
+
+	var count int64
+	for rows.Next() {
+		columns, err := scanNullStrings(rows, len(cols))
+		if err != nil {
+			return count, err
+		}
+
+		record := make(map[string]interface{}, len(cols))
+		for i, column := range columns {
+			if column.Valid {
+				record[cols[i]] = column.String
+			} else {
+				record[cols[i]] = nil
+			}
+		}
+		err = encoder.Encode(record)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// scanNullStrings scans the current row as nullable strings
+func scanNullStrings(rows *sql.Rows, size int) ([]sql.NullString, error) {
+	columns := make([]sql.NullString, size)
+	columnPointers := make([]interface{}, size)
+	for i := range columns {
+		columnPointers[i] = &columns[i]
+	}
+	err := rows.Scan(columnPointers...)
+	return columns, err
+}
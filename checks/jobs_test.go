@@ -0,0 +1,72 @@
+package checks
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportKey(t *testing.T) {
+	job := Jobs{
+		Name:   "orders",
+		Prefix: "exports/",
+	}
+
+	assert.Equal(t, ExportKey(job, "instance", "test"), "exports/instance/test/orders.csv")
+
+	job.Format = "jsonl"
+	assert.Equal(t, ExportKey(job, "instance", "test"), "exports/instance/test/orders.jsonl")
+}
+
+func TestExportQueryUnsupportedFormat(t *testing.T) {
+	c := &Client{}
+
+	_, err := c.ExportQuery("SELECT id FROM orders", "parquet", "exports", "orders.parquet")
+	assert.NotNil(t, err)
+}
+
+func TestWriteCSV(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mockdb.ExpectQuery("SELECT id, email FROM users").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).
+		AddRow(int64(1), "test@example.com").
+		AddRow(int64(2), nil))
+
+	rows, err := db.Query("SELECT id, email FROM users")
+	assert.Nil(t, err)
+	defer rows.Close()
+
+	buf := new(bytes.Buffer)
+	count, err := writeCSV(rows, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(2))
+	assert.Equal(t, buf.String(), "id,email\n1,test@example.com\n2,\n")
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mockdb.ExpectQuery("SELECT id, email FROM users").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).
+		AddRow(int64(1), "test@example.com").
+		AddRow(int64(2), nil))
+
+	rows, err := db.Query("SELECT id, email FROM users")
+	assert.Nil(t, err)
+	defer rows.Close()
+
+	buf := new(bytes.Buffer)
+	count, err := writeJSONLines(rows, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, count, int64(2))
+	assert.Equal(t, buf.String(), "{\"email\":\"test@example.com\",\"id\":\"1\"}\n{\"email\":null,\"id\":\"2\"}\n")
+}
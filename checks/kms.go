@@ -0,0 +1,85 @@
+package checks
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// kmsPrefix marks the config values holding a base64 KMS ciphertext
+const kmsPrefix = "kms:"
+
+// minKmsSecretLength is the length from which a decrypted value is redacted from the logs.
+// Shorter values like a user name would be redacted everywhere, the passwords are always
+// registered as secrets by UnmarshalYamlFile.
+const minKmsSecretLength = 12
+
+// decryptValues walks the config and replaces the values starting with kmsPrefix by their plaintext.
+// path is only used to tell which value could not be decrypted. The plaintexts of at least
+// minKmsSecretLength characters are redacted from the logs.
+func (c *Client) decryptValues(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		if !strings.HasPrefix(v.String(), kmsPrefix) {
+			return nil
+		}
+		plaintext, err := c.decrypt(strings.TrimPrefix(v.String(), kmsPrefix))
+		if err != nil {
+			return fmt.Errorf("Could not decrypt %s: %v", path, err)
+		}
+		if len(plaintext) >= minKmsSecretLength {
+			AddSecret(plaintext)
+		}
+		v.SetString(plaintext)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("yaml")
+			if name == "" {
+				name = strings.ToLower(v.Type().Field(i).Name)
+			}
+			err := c.decryptValues(v.Field(i), path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			err := c.decryptValues(v.Index(i), path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decrypt decrypts a base64 KMS ciphertext
+func (c *Client) decrypt(ciphertext string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	o, err := c.KMS.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: blob,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(o.Plaintext), nil
+}
+
+// GetKmsKeyArn returns the arn of a kms key from its id, arn or alias
+func (c *Client) GetKmsKeyArn(kmsid string) (string, error) {
+	o, err := c.KMS.DescribeKey(&kms.DescribeKeyInput{
+		KeyId: aws.String(kmsid),
+	})
+	if err != nil {
+		return "", err
+	}
+	return *o.KeyMetadata.Arn, nil
+}
@@ -0,0 +1,123 @@
+package checks
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockKMS struct {
+	kmsiface.KMSAPI
+	mock.Mock
+}
+
+func (m *mockKMS) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*kms.DecryptOutput), args.Error(1)
+}
+
+func (m *mockKMS) DescribeKey(input *kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*kms.DescribeKeyOutput), args.Error(1)
+}
+
+var kmsYaml = `
+instances:
+  - name: rdscheck
+    password: "kms:Y2lwaGVydGV4dA=="
+    queries:
+      - query: "SELECT 1;"
+        regex: "kms:Y2lwaGVydGV4dA=="
+`
+
+func TestUnmarshalYamlFileKMS(t *testing.T) {
+	mockKMS := &mockKMS{}
+	c := &Client{
+		KMS: mockKMS,
+	}
+
+	mockKMS.On("Decrypt", &kms.DecryptInput{
+		CiphertextBlob: []byte("ciphertext"),
+	}).Return(&kms.DecryptOutput{
+		Plaintext: []byte("thisisatest"),
+	}, nil)
+
+	value, err := c.UnmarshalYamlFile(bytes.NewReader([]byte(kmsYaml)))
+	assert.Nil(t, err)
+	assert.Equal(t, value.Instances[0].Password, "thisisatest")
+	assert.Equal(t, value.Instances[0].Queries[0].Regex, "thisisatest")
+	assert.Equal(t, value.Instances[0].Queries[0].Query, "SELECT 1;")
+	mockKMS.AssertNumberOfCalls(t, "Decrypt", 2)
+}
+
+func TestUnmarshalYamlFileKMSRedactsLongValues(t *testing.T) {
+	mockKMS := &mockKMS{}
+	c := &Client{
+		KMS: mockKMS,
+	}
+
+	mockKMS.On("Decrypt", &kms.DecryptInput{
+		CiphertextBlob: []byte("ciphertext"),
+	}).Return(&kms.DecryptOutput{
+		Plaintext: []byte("kmspassword"),
+	}, nil)
+	mockKMS.On("Decrypt", &kms.DecryptInput{
+		CiphertextBlob: []byte("database"),
+	}).Return(&kms.DecryptOutput{
+		Plaintext: []byte("postgres"),
+	}, nil)
+	mockKMS.On("Decrypt", &kms.DecryptInput{
+		CiphertextBlob: []byte("rolearn"),
+	}).Return(&kms.DecryptOutput{
+		Plaintext: []byte("arn:aws:iam::210987654321:role/rdscheck-kms"),
+	}, nil)
+
+	_, err := c.UnmarshalYamlFile(bytes.NewReader([]byte(`
+instances:
+  - name: rdscheck
+    database: "kms:ZGF0YWJhc2U="
+    password: "kms:Y2lwaGVydGV4dA=="
+    role_arn: "kms:cm9sZWFybg=="
+`)))
+	assert.Nil(t, err)
+	assert.Equal(t, "user=postgres password=REDACTED", Redact("user=postgres password=kmspassword"))
+	assert.Equal(t, "REDACTED", Redact("kmspassword"))
+	assert.Equal(t, "assuming REDACTED", Redact("assuming arn:aws:iam::210987654321:role/rdscheck-kms"))
+}
+
+func TestUnmarshalYamlFileKMSError(t *testing.T) {
+	mockKMS := &mockKMS{}
+	c := &Client{
+		KMS: mockKMS,
+	}
+
+	mockKMS.On("Decrypt", mock.Anything).Return(&kms.DecryptOutput{}, errors.New("AccessDeniedException"))
+
+	_, err := c.UnmarshalYamlFile(bytes.NewReader([]byte(kmsYaml)))
+	assert.EqualError(t, err, "Could not decrypt .instances[0].password: AccessDeniedException")
+}
+
+func TestGetKmsKeyArn(t *testing.T) {
+	mockKMS := &mockKMS{}
+	c := &Client{
+		KMS: mockKMS,
+	}
+
+	mockKMS.On("DescribeKey", &kms.DescribeKeyInput{
+		KeyId: aws.String("alias/rdscheck"),
+	}).Return(&kms.DescribeKeyOutput{
+		KeyMetadata: &kms.KeyMetadata{
+			Arn: aws.String("arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456"),
+		},
+	}, nil)
+
+	value, err := c.GetKmsKeyArn("alias/rdscheck")
+	assert.Nil(t, err)
+	assert.Equal(t, value, "arn:aws:kms:us-east-1:1234567890:key/123456-7890-123456")
+}
@@ -0,0 +1,137 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// LogMatch is a line of a rds log file that matched one of the patterns
+type LogMatch struct {
+	File    string
+	Line    string
+	Pattern string
+}
+
+// ScanDBLogs downloads the log files of the rds instance restored from a snapshot
+// and returns the lines matching any of the patterns
+func (c *Client) ScanDBLogs(snapshot *rds.DBSnapshot, patterns []string) ([]LogMatch, error) {
+	regexes := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexes = append(regexes, r)
+	}
+
+	files, err := c.getDBLogFiles(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []LogMatch
+	for _, file := range files {
+		err := c.scanDBLogFile(snapshot, file, func(line string) {
+			for i, r := range regexes {
+				if r.MatchString(line) {
+					matches = append(matches, LogMatch{
+						File:    file,
+						Line:    line,
+						Pattern: patterns[i],
+					})
+					break
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// validateLogPatterns returns an error if a logs pattern of an instance is not a valid regular expression
+func validateLogPatterns(doc Doc) error {
+	for _, instance := range doc.Instances {
+		for _, pattern := range append(append([]string{}, instance.Logs.Fail...), instance.Logs.Warn...) {
+			_, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid logs pattern of %s: %v", instance.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getDBLogFiles returns the name of all the log files of a rds instance
+func (c *Client) getDBLogFiles(snapshot *rds.DBSnapshot) ([]string, error) {
+	input := &rds.DescribeDBLogFilesInput{
+		DBInstanceIdentifier: aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
+	}
+
+	var files []string
+	for {
+		o, err := c.RDS.DescribeDBLogFiles(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range o.DescribeDBLogFiles {
+			files = append(files, *f.LogFileName)
+		}
+		if o.Marker == nil || *o.Marker == "" {
+			return files, nil
+		}
+		input.Marker = o.Marker
+	}
+}
+
+// maxLogLine is the longest log line kept in memory, the rest of a longer line is not scanned
+const maxLogLine = 1024 * 1024
+
+// scanDBLogFile calls scan with each line of a rds instance log file.
+// The file is scanned portion by portion as it is downloaded, so it is never fully held in memory.
+func (c *Client) scanDBLogFile(snapshot *rds.DBSnapshot, file string, scan func(line string)) error {
+	input := &rds.DownloadDBLogFilePortionInput{
+		DBInstanceIdentifier: aws.String(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
+		LogFileName:          aws.String(file),
+		Marker:               aws.String("0"),
+	}
+
+	// partial is the last line of the previous portion when it didn't end with a newline
+	partial := ""
+	truncated := false
+	for {
+		o, err := c.RDS.DownloadDBLogFilePortion(input)
+		if err != nil {
+			return err
+		}
+		if o.LogFileData != nil {
+			lines := strings.Split(partial+*o.LogFileData, "\n")
+			for _, line := range lines[:len(lines)-1] {
+				if !truncated {
+					scan(strings.TrimSuffix(line, "\r"))
+				}
+				truncated = false
+			}
+			partial = lines[len(lines)-1]
+			if len(partial) > maxLogLine {
+				if !truncated {
+					scan(partial[:maxLogLine])
+				}
+				partial = ""
+				truncated = true
+			}
+		}
+		if o.AdditionalDataPending == nil || !*o.AdditionalDataPending {
+			if partial != "" && !truncated {
+				scan(strings.TrimSuffix(partial, "\r"))
+			}
+			return nil
+		}
+		input.Marker = o.Marker
+	}
+}
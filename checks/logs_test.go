@@ -0,0 +1,139 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestScanDBLogs(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	rdsc.On("DescribeDBLogFiles", mock.Anything).Return(&rds.DescribeDBLogFilesOutput{
+		DescribeDBLogFiles: []*rds.DescribeDBLogFilesDetails{
+			&rds.DescribeDBLogFilesDetails{
+				LogFileName: aws.String("error/postgresql.log.2019-11-20-00"),
+			},
+		},
+	}, nil)
+
+	rdsc.On("DownloadDBLogFilePortion", mock.MatchedBy(func(input *rds.DownloadDBLogFilePortionInput) bool {
+		return *input.Marker == "0"
+	})).Return(&rds.DownloadDBLogFilePortionOutput{
+		LogFileData:           aws.String("LOG:  database system is ready to accept connections\n"),
+		Marker:                aws.String("1"),
+		AdditionalDataPending: aws.Bool(true),
+	}, nil)
+
+	rdsc.On("DownloadDBLogFilePortion", mock.MatchedBy(func(input *rds.DownloadDBLogFilePortionInput) bool {
+		return *input.Marker == "1"
+	})).Return(&rds.DownloadDBLogFilePortionOutput{
+		LogFileData:           aws.String("ERROR:  invalid page in block 42 of relation base/16384/16385\nPANIC:  could not locate a valid checkpoint record\n"),
+		Marker:                aws.String("2"),
+		AdditionalDataPending: aws.Bool(false),
+	}, nil)
+
+	value, err := c.ScanDBLogs(input, []string{"PANIC", "invalid page in block"})
+	assert.Nil(t, err)
+	assert.Len(t, value, 2, "Expect two matches")
+	assert.Equal(t, value[0].Pattern, "invalid page in block")
+	assert.Equal(t, value[1].Pattern, "PANIC")
+	assert.Equal(t, value[1].File, "error/postgresql.log.2019-11-20-00")
+	rdsc.AssertExpectations(t)
+}
+
+func TestScanDBLogsInvalidPattern(t *testing.T) {
+	c := &Client{}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	_, err := c.ScanDBLogs(input, []string{"("})
+	assert.NotNil(t, err)
+}
+
+func TestScanDBLogsLineAcrossPortions(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBInstanceIdentifier: aws.String("instance"),
+	}
+
+	rdsc.On("DescribeDBLogFiles", mock.Anything).Return(&rds.DescribeDBLogFilesOutput{
+		DescribeDBLogFiles: []*rds.DescribeDBLogFilesDetails{
+			&rds.DescribeDBLogFilesDetails{
+				LogFileName: aws.String("error/postgresql.log.2019-11-20-00"),
+			},
+		},
+	}, nil)
+
+	// the PANIC line is split between the two portions, and the last line has no newline
+	rdsc.On("DownloadDBLogFilePortion", mock.MatchedBy(func(input *rds.DownloadDBLogFilePortionInput) bool {
+		return *input.Marker == "0"
+	})).Return(&rds.DownloadDBLogFilePortionOutput{
+		LogFileData:           aws.String("LOG:  database system is ready to accept connections\nPAN"),
+		Marker:                aws.String("1"),
+		AdditionalDataPending: aws.Bool(true),
+	}, nil)
+
+	rdsc.On("DownloadDBLogFilePortion", mock.MatchedBy(func(input *rds.DownloadDBLogFilePortionInput) bool {
+		return *input.Marker == "1"
+	})).Return(&rds.DownloadDBLogFilePortionOutput{
+		LogFileData:           aws.String("IC:  could not locate a valid checkpoint record\nERROR:  invalid page in block 42"),
+		Marker:                aws.String("2"),
+		AdditionalDataPending: aws.Bool(false),
+	}, nil)
+
+	value, err := c.ScanDBLogs(input, []string{"^PANIC", "invalid page in block"})
+	assert.Nil(t, err)
+	assert.Len(t, value, 2)
+	assert.Equal(t, "PANIC:  could not locate a valid checkpoint record", value[0].Line)
+	assert.Equal(t, "ERROR:  invalid page in block 42", value[1].Line)
+	rdsc.AssertExpectations(t)
+}
+
+func TestValidateLogPatterns(t *testing.T) {
+	valid := Doc{
+		Instances: []Instances{
+			Instances{
+				Name: "test",
+				Logs: Logs{
+					Fail: []string{"PANIC"},
+					Warn: []string{"invalid page in block \\d+"},
+				},
+			},
+		},
+	}
+	assert.Nil(t, validateLogPatterns(valid))
+
+	invalid := Doc{
+		Instances: []Instances{
+			Instances{
+				Name: "test",
+				Logs: Logs{
+					Warn: []string{"PANIC("},
+				},
+			},
+		},
+	}
+	assert.EqualError(t, validateLogPatterns(invalid), "invalid logs pattern of test: error parsing regexp: missing closing ): `PANIC(`")
+}
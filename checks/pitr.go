@@ -0,0 +1,240 @@
+package checks
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/config"
+	"github.com/techdroplabs/rdscheck/utils"
+)
+
+// pitrSuffix is appended to the source instance name to name the instance
+// restored to a point in time. We only run one point in time restore per instance at a time.
+const pitrSuffix = "pitr"
+
+// pitrSubnetGroupName returns the name of the subnet group used by the point in time restores
+// of an instance. We keep it around between restores to remember when the last one started.
+func pitrSubnetGroupName(DBInstanceIdentifier string) string {
+	return "rdscheck-pitr-" + DBInstanceIdentifier
+}
+
+// GetPointInTimeRestore returns the point in time restore in progress for an instance,
+// or nil if there is none.
+// The restore is described as a snapshot whose arn is the restored instance arn
+// so it can go through the same states as the snapshots restores.
+func (c *Client) GetPointInTimeRestore(DBInstanceIdentifier string) (*rds.DBSnapshot, error) {
+	input := &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(DBInstanceIdentifier + "-" + pitrSuffix),
+	}
+	o, err := c.RDS.DescribeDBInstances(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, db := range o.DBInstances {
+		return &rds.DBSnapshot{
+			DBInstanceIdentifier: aws.String(DBInstanceIdentifier),
+			DBSnapshotIdentifier: aws.String(pitrSuffix),
+			DBSnapshotArn:        db.DBInstanceArn,
+			Engine:               db.Engine,
+			Port:                 db.DbInstancePort,
+		}, nil
+	}
+	return nil, nil
+}
+
+// IsPointInTime tells if a snapshot describes a point in time restore returned by GetPointInTimeRestore.
+// They only go through the modify, verify, alarm and clean states.
+func IsPointInTime(snapshot *rds.DBSnapshot) bool {
+	return snapshot.SnapshotType == nil && aws.StringValue(snapshot.DBSnapshotIdentifier) == pitrSuffix
+}
+
+// PointInTimeRestoreDue returns true if the last point in time restore of an instance
+// started more than interval hours ago
+func (c *Client) PointInTimeRestoreDue(DBInstanceIdentifier string, interval int) bool {
+	input := &rds.DescribeDBSubnetGroupsInput{
+		DBSubnetGroupName: aws.String(pitrSubnetGroupName(DBInstanceIdentifier)),
+	}
+	o, err := c.RDS.DescribeDBSubnetGroups(input)
+	if err != nil {
+		return true
+	}
+	for _, group := range o.DBSubnetGroups {
+		last, err := strconv.ParseInt(c.GetTagValue(*group.DBSubnetGroupArn, "LastRestoreTime"), 10, 64)
+		if err != nil {
+			return true
+		}
+		return time.Since(time.Unix(last, 0)) >= time.Duration(interval)*time.Hour
+	}
+	return true
+}
+
+// CreateDBFromPointInTime restores an instance to a random point in time within its restore window
+// and returns the time it was restored to.
+// iamauth enables the IAM database authentication on the restored instance, which doesn't need a password reset.
+func (c *Client) CreateDBFromPointInTime(DBInstanceIdentifier, instancetype string, vpcsecuritygroupids, subnetids []string, iamauth bool) (time.Time, error) {
+	o, err := c.RDS.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(DBInstanceIdentifier),
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(o.DBInstances) == 0 {
+		return time.Time{}, errors.New("Could not find the source instance")
+	}
+	source := o.DBInstances[0]
+
+	if source.LatestRestorableTime == nil || *source.BackupRetentionPeriod == 0 {
+		return time.Time{}, errors.New("Automated backups are not enabled on the source instance")
+	}
+
+	latest := *source.LatestRestorableTime
+	earliest, err := c.earliestRestorableTime(source)
+	if err != nil {
+		return time.Time{}, err
+	}
+	restoreTime := latest
+	if latest.After(earliest) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		restoreTime = earliest.Add(time.Duration(r.Int63n(int64(latest.Sub(earliest)))))
+	}
+
+	subnetGroupArn, err := c.getPointInTimeSubnetGroup(DBInstanceIdentifier, subnetids)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	status := "modify"
+	if iamauth {
+		status = "verify"
+	}
+
+	input := &rds.RestoreDBInstanceToPointInTimeInput{
+		AutoMinorVersionUpgrade:         aws.Bool(false),
+		DBInstanceClass:                 aws.String(instancetype),
+		DBSubnetGroupName:               aws.String(pitrSubnetGroupName(DBInstanceIdentifier)),
+		DeletionProtection:              aws.Bool(false),
+		EnableIAMDatabaseAuthentication: aws.Bool(iamauth),
+		MultiAZ:                         aws.Bool(false),
+		PubliclyAccessible:              aws.Bool(false),
+		RestoreTime:                     aws.Time(restoreTime),
+		SourceDBInstanceIdentifier:      aws.String(DBInstanceIdentifier),
+		TargetDBInstanceIdentifier:      aws.String(DBInstanceIdentifier + "-" + pitrSuffix),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("CreatedBy"),
+				Value: aws.String("rdscheck"),
+			},
+			{
+				Key:   aws.String("RestoreTime"),
+				Value: aws.String(restoreTime.UTC().Format(time.RFC3339)),
+			},
+			{
+				Key:   aws.String("RestoreStartTime"),
+				Value: aws.String(utils.GetUnixTimeAsString()),
+			},
+			{
+				Key:   aws.String("Status"),
+				Value: aws.String(status),
+			},
+		},
+		VpcSecurityGroupIds: aws.StringSlice(vpcsecuritygroupids),
+	}
+
+	_, err = c.RDS.RestoreDBInstanceToPointInTime(input)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	_, err = c.RDS.AddTagsToResource(&rds.AddTagsToResourceInput{
+		ResourceName: aws.String(subnetGroupArn),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("LastRestoreTime"),
+				Value: aws.String(utils.GetUnixTimeAsString()),
+			},
+		},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	log.WithFields(log.Fields{
+		"RDS Instance": DBInstanceIdentifier,
+		"Restore Time": restoreTime,
+	}).Info("Point in time restore started")
+
+	return restoreTime, nil
+}
+
+// earliestRestorableTime returns the start of the restore window of the automated backups of an instance
+func (c *Client) earliestRestorableTime(source *rds.DBInstance) (time.Time, error) {
+	o, err := c.RDS.DescribeDBInstanceAutomatedBackups(&rds.DescribeDBInstanceAutomatedBackupsInput{
+		DbiResourceId: source.DbiResourceId,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, backup := range o.DBInstanceAutomatedBackups {
+		if backup.RestoreWindow != nil && backup.RestoreWindow.EarliestTime != nil {
+			return *backup.RestoreWindow.EarliestTime, nil
+		}
+	}
+	return time.Time{}, errors.New("Could not find the restore window of the source instance")
+}
+
+// PointInTimeNetwork returns the subnets and the security groups of the point in time restores
+// of an instance. They run in the source region so they can't use the destination ones.
+func PointInTimeNetwork(pitr PITR) ([]string, []string) {
+	subnets := pitr.SubnetIds
+	if len(subnets) == 0 {
+		subnets = config.PITRSubnetIds
+	}
+	securityGroups := pitr.SecurityGroupIds
+	if len(securityGroups) == 0 {
+		securityGroups = config.PITRSecurityGroupIds
+	}
+	return subnets, securityGroups
+}
+
+// getPointInTimeSubnetGroup returns the arn of the subnet group used by the point in time restores
+// of an instance and creates it if it doesn't exist yet
+func (c *Client) getPointInTimeSubnetGroup(DBInstanceIdentifier string, subnetids []string) (string, error) {
+	name := pitrSubnetGroupName(DBInstanceIdentifier)
+
+	o, err := c.RDS.DescribeDBSubnetGroups(&rds.DescribeDBSubnetGroupsInput{
+		DBSubnetGroupName: aws.String(name),
+	})
+	if err == nil {
+		for _, group := range o.DBSubnetGroups {
+			return *group.DBSubnetGroupArn, nil
+		}
+	} else if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != rds.ErrCodeDBSubnetGroupNotFoundFault {
+		return "", err
+	}
+
+	input := &rds.CreateDBSubnetGroupInput{
+		DBSubnetGroupDescription: aws.String(name),
+		DBSubnetGroupName:        aws.String(name),
+		SubnetIds:                aws.StringSlice(subnetids),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("CreatedBy"),
+				Value: aws.String("rdscheck"),
+			},
+		},
+	}
+	created, err := c.RDS.CreateDBSubnetGroup(input)
+	if err != nil {
+		return "", err
+	}
+	return *created.DBSubnetGroup.DBSubnetGroupArn, nil
+}
@@ -0,0 +1,250 @@
+package checks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetPointInTimeRestore(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			&rds.DBInstance{
+				DBInstanceIdentifier: aws.String("instance-pitr"),
+				DBInstanceArn:        aws.String("arn:aws:rds:us-west-2:123456789012:db:instance-pitr"),
+				Engine:               aws.String("postgres"),
+				DbInstancePort:       aws.Int64(5432),
+			},
+		},
+	}, nil)
+
+	value, err := c.GetPointInTimeRestore("instance")
+	assert.Nil(t, err)
+	assert.Equal(t, *value.DBInstanceIdentifier, "instance")
+	assert.Equal(t, *value.DBSnapshotIdentifier, "pitr")
+	assert.Equal(t, *value.DBSnapshotArn, "arn:aws:rds:us-west-2:123456789012:db:instance-pitr")
+	rdsc.AssertExpectations(t)
+}
+
+func TestGetPointInTimeRestoreNotFound(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{},
+		awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "not found", nil))
+
+	value, err := c.GetPointInTimeRestore("instance")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	rdsc.AssertExpectations(t)
+}
+
+func TestPointInTimeRestoreDue(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	last := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+
+	rdsc.On("DescribeDBSubnetGroups", mock.Anything).Return(&rds.DescribeDBSubnetGroupsOutput{
+		DBSubnetGroups: []*rds.DBSubnetGroup{
+			&rds.DBSubnetGroup{
+				DBSubnetGroupArn: aws.String("arn:aws:rds:us-west-2:123456789012:subgrp:rdscheck-pitr-instance"),
+			},
+		},
+	}, nil)
+
+	rdsc.On("ListTagsForResource", mock.Anything).Return(&rds.ListTagsForResourceOutput{
+		TagList: []*rds.Tag{
+			&rds.Tag{
+				Key:   aws.String("LastRestoreTime"),
+				Value: aws.String(last),
+			},
+		},
+	}, nil)
+
+	assert.True(t, c.PointInTimeRestoreDue("instance", 1))
+	assert.False(t, c.PointInTimeRestoreDue("instance", 24))
+	rdsc.AssertExpectations(t)
+}
+
+func TestCreateDBFromPointInTime(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	latest := time.Now().Add(-5 * time.Minute)
+	earliest := time.Now().Add(-2 * time.Hour)
+
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			&rds.DBInstance{
+				DBInstanceIdentifier:  aws.String("instance"),
+				DbiResourceId:         aws.String("db-ABCDEFGHIJKL"),
+				BackupRetentionPeriod: aws.Int64(7),
+				InstanceCreateTime:    aws.Time(time.Now().AddDate(-1, 0, 0)),
+				LatestRestorableTime:  aws.Time(latest),
+			},
+		},
+	}, nil)
+
+	rdsc.On("DescribeDBInstanceAutomatedBackups", &rds.DescribeDBInstanceAutomatedBackupsInput{
+		DbiResourceId: aws.String("db-ABCDEFGHIJKL"),
+	}).Return(&rds.DescribeDBInstanceAutomatedBackupsOutput{
+		DBInstanceAutomatedBackups: []*rds.DBInstanceAutomatedBackup{
+			&rds.DBInstanceAutomatedBackup{
+				RestoreWindow: &rds.RestoreWindow{
+					EarliestTime: aws.Time(earliest),
+					LatestTime:   aws.Time(latest),
+				},
+			},
+		},
+	}, nil)
+
+	rdsc.On("DescribeDBSubnetGroups", mock.Anything).Return(&rds.DescribeDBSubnetGroupsOutput{},
+		awserr.New(rds.ErrCodeDBSubnetGroupNotFoundFault, "not found", nil))
+
+	rdsc.On("CreateDBSubnetGroup", mock.Anything).Return(&rds.CreateDBSubnetGroupOutput{
+		DBSubnetGroup: &rds.DBSubnetGroup{
+			DBSubnetGroupArn: aws.String("arn:aws:rds:us-west-2:123456789012:subgrp:rdscheck-pitr-instance"),
+		},
+	}, nil)
+
+	rdsc.On("RestoreDBInstanceToPointInTime", mock.Anything).Return(&rds.RestoreDBInstanceToPointInTimeOutput{
+		DBInstance: &rds.DBInstance{},
+	}, nil)
+
+	rdsc.On("AddTagsToResource", mock.Anything).Return(&rds.AddTagsToResourceOutput{}, nil)
+
+	value, err := c.CreateDBFromPointInTime("instance", "db.t2.micro", []string{"sg-12345"}, []string{"subnet-12345"}, false)
+	assert.Nil(t, err)
+	assert.False(t, value.Before(earliest))
+	assert.False(t, value.After(latest))
+	rdsc.AssertExpectations(t)
+}
+
+func TestCreateDBFromPointInTimeIAMAuth(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	latest := time.Now().Add(-5 * time.Minute)
+	earliest := time.Now().Add(-2 * time.Hour)
+
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			&rds.DBInstance{
+				DBInstanceIdentifier:  aws.String("instance"),
+				DbiResourceId:         aws.String("db-ABCDEFGHIJKL"),
+				BackupRetentionPeriod: aws.Int64(7),
+				InstanceCreateTime:    aws.Time(time.Now().AddDate(-1, 0, 0)),
+				LatestRestorableTime:  aws.Time(latest),
+			},
+		},
+	}, nil)
+
+	rdsc.On("DescribeDBInstanceAutomatedBackups", &rds.DescribeDBInstanceAutomatedBackupsInput{
+		DbiResourceId: aws.String("db-ABCDEFGHIJKL"),
+	}).Return(&rds.DescribeDBInstanceAutomatedBackupsOutput{
+		DBInstanceAutomatedBackups: []*rds.DBInstanceAutomatedBackup{
+			&rds.DBInstanceAutomatedBackup{
+				RestoreWindow: &rds.RestoreWindow{
+					EarliestTime: aws.Time(earliest),
+					LatestTime:   aws.Time(latest),
+				},
+			},
+		},
+	}, nil)
+
+	rdsc.On("DescribeDBSubnetGroups", mock.Anything).Return(&rds.DescribeDBSubnetGroupsOutput{},
+		awserr.New(rds.ErrCodeDBSubnetGroupNotFoundFault, "not found", nil))
+
+	rdsc.On("CreateDBSubnetGroup", mock.Anything).Return(&rds.CreateDBSubnetGroupOutput{
+		DBSubnetGroup: &rds.DBSubnetGroup{
+			DBSubnetGroupArn: aws.String("arn:aws:rds:us-west-2:123456789012:subgrp:rdscheck-pitr-instance"),
+		},
+	}, nil)
+
+	rdsc.On("RestoreDBInstanceToPointInTime", mock.MatchedBy(func(input *rds.RestoreDBInstanceToPointInTimeInput) bool {
+		for _, tag := range input.Tags {
+			if *tag.Key == "Status" {
+				return *input.EnableIAMDatabaseAuthentication && *tag.Value == "verify"
+			}
+		}
+		return false
+	})).Return(&rds.RestoreDBInstanceToPointInTimeOutput{
+		DBInstance: &rds.DBInstance{},
+	}, nil)
+
+	rdsc.On("AddTagsToResource", mock.Anything).Return(&rds.AddTagsToResourceOutput{}, nil)
+
+	_, err := c.CreateDBFromPointInTime("instance", "db.t2.micro", []string{"sg-12345"}, []string{"subnet-12345"}, true)
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
+func TestCreateDBFromPointInTimeNoBackups(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBInstances", mock.Anything).Return(&rds.DescribeDBInstancesOutput{
+		DBInstances: []*rds.DBInstance{
+			&rds.DBInstance{
+				DBInstanceIdentifier:  aws.String("instance"),
+				BackupRetentionPeriod: aws.Int64(0),
+			},
+		},
+	}, nil)
+
+	_, err := c.CreateDBFromPointInTime("instance", "db.t2.micro", []string{"sg-12345"}, []string{"subnet-12345"}, false)
+	assert.NotNil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
+func TestPointInTimeNetwork(t *testing.T) {
+	subnets, securityGroups := PointInTimeNetwork(PITR{
+		SubnetIds:        []string{"subnet-source"},
+		SecurityGroupIds: []string{"sg-source"},
+	})
+	assert.Equal(t, []string{"subnet-source"}, subnets)
+	assert.Equal(t, []string{"sg-source"}, securityGroups)
+}
+
+func TestIsPointInTime(t *testing.T) {
+	assert.True(t, IsPointInTime(&rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("test"),
+		DBSnapshotIdentifier: aws.String("pitr"),
+	}))
+	assert.False(t, IsPointInTime(&rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("pitr"),
+		SnapshotType:         aws.String("manual"),
+	}))
+	assert.False(t, IsPointInTime(&rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2020-06-30-03-00"),
+		SnapshotType:         aws.String("automated"),
+	}))
+}
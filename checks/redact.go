@@ -0,0 +1,104 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+// redacted replaces the secrets in logs and outputs
+const redacted = "REDACTED"
+
+// redactPatterns mask the secrets we can recognize without knowing them:
+// passwords in DSNs and urls, presigned urls (which IAM authentication tokens also are) and api keys in urls
+var redactPatterns = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`(?i)(password=)('[^']*'|[^\s&]+)`), "${1}" + redacted},
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`), "${1}" + redacted + "@"},
+	{regexp.MustCompile(`(?i)(X-Amz-(?:Signature|Security-Token|Credential)=)[^&\s"]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)((?:api_key|application_key)=)[^&\s"]+`), "${1}" + redacted},
+}
+
+// secrets are the known secret values, like the configured passwords
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+func init() {
+	AddSecret(config.DDApiKey)
+	AddSecret(config.DDAplicationKey)
+}
+
+// AddSecret registers a value that must never appear in logs or outputs
+func AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, value := range secrets.values {
+		if value == secret {
+			return
+		}
+	}
+	secrets.values = append(secrets.values, secret)
+}
+
+// Redact masks the registered secrets and the recognizable secrets in s
+func Redact(s string) string {
+	for _, p := range redactPatterns {
+		s = p.pattern.ReplaceAllString(s, p.replace)
+	}
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, value := range secrets.values {
+		s = strings.Replace(s, value, redacted, -1)
+	}
+	return s
+}
+
+// redactAll masks the secrets of every string of values
+func redactAll(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = Redact(value)
+	}
+	return result
+}
+
+// RedactHook is a logrus hook masking the secrets in the message and the fields of every log entry
+type RedactHook struct{}
+
+// Levels returns all the log levels
+func (h *RedactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire redacts a log entry before it is written
+func (h *RedactHook) Fire(entry *log.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Redact(v)
+		case error:
+			if r := Redact(v.Error()); r != v.Error() {
+				entry.Data[key] = errors.New(r)
+			}
+		default:
+			s := fmt.Sprint(v)
+			if r := Redact(s); r != s {
+				entry.Data[key] = r
+			}
+		}
+	}
+	return nil
+}
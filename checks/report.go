@@ -0,0 +1,190 @@
+package checks
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// reportQueries are the diagnostic queries we always run on a restored database, by engine.
+// Usage statistics (like index scans or dead tuples) are reset by a restore so we mostly rely on the catalog.
+var reportQueries = map[string][]ReportQueries{
+	"postgres": {
+		{
+			Name: "largest_tables",
+			Query: `SELECT schemaname, relname, pg_total_relation_size(relid) AS total_bytes, pg_relation_size(relid) AS table_bytes
+FROM pg_catalog.pg_statio_user_tables ORDER BY 3 DESC LIMIT 20`,
+		},
+		{
+			Name: "largest_indexes",
+			Query: `SELECT schemaname, relname, indexrelname, pg_relation_size(indexrelid) AS index_bytes
+FROM pg_catalog.pg_statio_user_indexes ORDER BY 4 DESC LIMIT 20`,
+		},
+		{
+			Name: "unused_indexes",
+			Query: `SELECT s.schemaname, s.relname, s.indexrelname, s.idx_scan, pg_relation_size(s.indexrelid) AS index_bytes
+FROM pg_catalog.pg_stat_user_indexes s
+JOIN pg_catalog.pg_index i ON i.indexrelid = s.indexrelid
+WHERE s.idx_scan = 0 AND NOT i.indisunique AND NOT i.indisprimary
+ORDER BY 5 DESC LIMIT 20`,
+		},
+		{
+			Name: "table_bloat_estimate",
+			Query: `SELECT n.nspname AS schemaname, c.relname AS tablename,
+c.relpages::bigint * current_setting('block_size')::bigint AS table_bytes,
+CEIL(c.reltuples * (24 + COALESCE(SUM(s.avg_width), 0)) / (current_setting('block_size')::numeric - 24))::bigint * current_setting('block_size')::bigint AS expected_bytes
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_stats s ON s.schemaname = n.nspname AND s.tablename = c.relname
+WHERE c.relkind = 'r' AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+GROUP BY n.nspname, c.relname, c.relpages, c.reltuples
+ORDER BY c.relpages DESC LIMIT 20`,
+		},
+		{
+			Name: "sequence_exhaustion",
+			Query: `SELECT schemaname, sequencename, last_value, max_value, ROUND(100.0 * COALESCE(last_value, 0) / max_value, 2) AS percent_used
+FROM pg_catalog.pg_sequences ORDER BY 5 DESC LIMIT 20`,
+		},
+		{
+			Name: "xid_age",
+			Query: `SELECT datname, age(datfrozenxid) AS xid_age, ROUND(100.0 * age(datfrozenxid) / 2147483647, 2) AS percent_towards_wraparound
+FROM pg_catalog.pg_database ORDER BY 2 DESC`,
+		},
+	},
+}
+
+// reportNotes explain how to read the sections of the built-in queries that are not exact
+var reportNotes = map[string]string{
+	"unused_indexes": "Best effort: the index usage statistics start empty on a restored instance, " +
+		"so this lists the indexes not used by the checks run before the report. Check them on production before dropping them.",
+}
+
+// Report is the result of the diagnostic queries run on a restored database
+type Report struct {
+	Instance    string          `json:"instance"`
+	Snapshot    string          `json:"snapshot"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Sections    []ReportSection `json:"sections"`
+}
+
+// ReportSection is the result of one diagnostic query
+type ReportSection struct {
+	Name    string     `json:"name"`
+	Query   string     `json:"query"`
+	Columns []string   `json:"columns,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+	Note    string     `json:"note,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// RunReport runs the built-in diagnostic queries of the engine and the extra queries on the database.
+// A failing query doesn't stop the report, its error is stored in its section.
+func (c *Client) RunReport(snapshot *rds.DBSnapshot, queries []ReportQueries) Report {
+	report := Report{
+		Instance:    *snapshot.DBInstanceIdentifier,
+		Snapshot:    *snapshot.DBSnapshotIdentifier,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	all := append(append([]ReportQueries{}, reportQueries[*snapshot.Engine]...), queries...)
+	for _, query := range all {
+		section := ReportSection{
+			Name:  query.Name,
+			Query: query.Query,
+			Note:  reportNotes[query.Name],
+		}
+
+		rows, err := c.DB.Query(query.Query)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Report": query.Name,
+			}).WithError(err).Warn("Could not run report query")
+			section.Error = err.Error()
+			report.Sections = append(report.Sections, section)
+			continue
+		}
+
+		section.Columns, section.Rows, err = scanRows(rows)
+		rows.Close()
+		if err != nil {
+			section.Error = err.Error()
+		}
+		report.Sections = append(report.Sections, section)
+	}
+	return report
+}
+
+// UploadReport writes a report as json to s3. The secrets are redacted from the report.
+func (c *Client) UploadReport(bucket, key string, report Report) error {
+	body, err := json.MarshalIndent(redactReport(report), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	_, err = c.S3.PutObject(input)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Bucket": bucket,
+		"Key":    key,
+	}).Info("Report uploaded")
+	return nil
+}
+
+// redactReport returns a copy of a report with the secrets redacted
+func redactReport(report Report) Report {
+	sections := make([]ReportSection, len(report.Sections))
+	for i, section := range report.Sections {
+		rows := make([][]string, len(section.Rows))
+		for j, row := range section.Rows {
+			rows[j] = redactAll(row)
+		}
+		sections[i] = ReportSection{
+			Name:    section.Name,
+			Query:   Redact(section.Query),
+			Columns: section.Columns,
+			Rows:    rows,
+			Note:    section.Note,
+			Error:   Redact(section.Error),
+		}
+	}
+	report.Sections = sections
+	return report
+}
+
+// scanRows reads all the rows as strings. NULL values are returned as empty strings.
+func scanRows(rows *sql.Rows) ([]string, [][]string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result [][]string
+	for rows.Next() {
+		columns, err := scanNullStrings(rows, len(cols))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := make([]string, len(cols))
+		for i, column := range columns {
+			row[i] = column.String
+		}
+		result = append(result, row)
+	}
+	return cols, result, rows.Err()
+}
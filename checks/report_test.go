@@ -0,0 +1,104 @@
+package checks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunReport(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	c := &Client{
+		DB: db,
+	}
+
+	input := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("instance"),
+		DBSnapshotIdentifier: aws.String("test"),
+		Engine:               aws.String("mysql"),
+	}
+
+	queries := []ReportQueries{
+		ReportQueries{
+			Name:  "users",
+			Query: "SELECT name, email FROM users",
+		},
+		ReportQueries{
+			Name:  "missing",
+			Query: "SELECT id FROM missing",
+		},
+	}
+
+	rows := sqlmock.NewRows([]string{"name", "email"}).
+		AddRow("rdscheck", nil)
+
+	mockdb.ExpectQuery("SELECT name, email FROM users").WillReturnRows(rows)
+	mockdb.ExpectQuery("SELECT id FROM missing").WillReturnError(errors.New("relation \"missing\" does not exist"))
+
+	value := c.RunReport(input, queries)
+	assert.Equal(t, value.Instance, "instance")
+	assert.Len(t, value.Sections, 2, "Expect two sections")
+	assert.Equal(t, value.Sections[0].Columns, []string{"name", "email"})
+	assert.Equal(t, value.Sections[0].Rows, [][]string{{"rdscheck", ""}})
+	assert.Equal(t, value.Sections[1].Error, "relation \"missing\" does not exist")
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
+
+func TestRunReportBuiltin(t *testing.T) {
+	db, mockdb, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	c := &Client{
+		DB: db,
+	}
+
+	input := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("instance"),
+		DBSnapshotIdentifier: aws.String("test"),
+		Engine:               aws.String("postgres"),
+	}
+
+	mockdb.MatchExpectationsInOrder(false)
+	for range reportQueries["postgres"] {
+		mockdb.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("1"))
+	}
+
+	value := c.RunReport(input, nil)
+	assert.Len(t, value.Sections, len(reportQueries["postgres"]))
+	for _, section := range value.Sections {
+		if section.Name == "unused_indexes" {
+			assert.NotEmpty(t, section.Note)
+		}
+	}
+	assert.Nil(t, mockdb.ExpectationsWereMet())
+}
+
+func TestUploadReport(t *testing.T) {
+	s3c := &mockS3{}
+
+	c := &Client{
+		S3: s3c,
+	}
+
+	s3c.On("PutObject", mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		return *input.Bucket == "reports" && *input.Key == "instance/test.json"
+	})).Return(&s3.PutObjectOutput{}, nil)
+
+	err := c.UploadReport("reports", "instance/test.json", Report{Instance: "instance", Snapshot: "test"})
+	assert.Nil(t, err)
+	s3c.AssertExpectations(t)
+}
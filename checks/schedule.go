@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduledPrefix starts the identifier of the snapshots created by the snapshot command
+const scheduledPrefix = "rdscheck-scheduled-"
+
+// defaultScheduleWindow is how many minutes after its time a scheduled snapshot can still be created
+const defaultScheduleWindow = 60
+
+// DueSlot returns the scheduled time a snapshot is due for, if any.
+// A time of schedule.At (HH:MM, UTC) is due during the schedule.Window minutes following it.
+func DueSlot(schedule Schedule, now time.Time) (time.Time, bool) {
+	window := schedule.Window
+	if window <= 0 {
+		window = defaultScheduleWindow
+	}
+	now = now.UTC()
+	for _, at := range schedule.At {
+		t, err := time.Parse("15:04", at)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"At": at,
+			}).WithError(err).Warn("Could not parse the schedule")
+			continue
+		}
+		// the window of yesterday's slot can go past midnight
+		for _, day := range []int{0, -1} {
+			slot := time.Date(now.Year(), now.Month(), now.Day()+day, t.Hour(), t.Minute(), 0, 0, time.UTC)
+			if !now.Before(slot) && now.Before(slot.Add(time.Duration(window)*time.Minute)) {
+				return slot, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ScheduledIdentifier returns the identifier of the snapshot of an instance for a scheduled time
+func ScheduledIdentifier(DBInstanceIdentifier string, slot time.Time) string {
+	return scheduledPrefix + DBInstanceIdentifier + "-" + slot.UTC().Format("2006-01-02-1504")
+}
+
+// IsScheduled tells if a snapshot was created by the snapshot command
+func IsScheduled(snapshot *rds.DBSnapshot) bool {
+	return aws.StringValue(snapshot.SnapshotType) == "manual" &&
+		strings.HasPrefix(aws.StringValue(snapshot.DBSnapshotIdentifier), scheduledPrefix)
+}
+
+// CreateScheduledSnapshot takes a manual snapshot of an instance for the snapshot command
+func (c *Client) CreateScheduledSnapshot(DBInstanceIdentifier, DBSnapshotIdentifier string) error {
+	input := &rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(DBInstanceIdentifier),
+		DBSnapshotIdentifier: aws.String(DBSnapshotIdentifier),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("ScheduledBy"),
+				Value: aws.String("rdscheck"),
+			},
+		},
+	}
+	_, err := c.RDS.CreateDBSnapshot(input)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"RDS Instance": DBInstanceIdentifier,
+		"Snapshot":     DBSnapshotIdentifier,
+	}).Info("Scheduled snapshot created")
+	return nil
+}
@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDueSlot(t *testing.T) {
+	schedule := Schedule{
+		At: []string{"02:30", "23:45"},
+	}
+
+	slot, due := DueSlot(schedule, time.Date(2020, 6, 30, 2, 40, 0, 0, time.UTC))
+	assert.True(t, due)
+	assert.Equal(t, time.Date(2020, 6, 30, 2, 30, 0, 0, time.UTC), slot)
+
+	_, due = DueSlot(schedule, time.Date(2020, 6, 30, 3, 30, 0, 0, time.UTC))
+	assert.False(t, due)
+
+	_, due = DueSlot(schedule, time.Date(2020, 6, 30, 2, 29, 0, 0, time.UTC))
+	assert.False(t, due)
+
+	slot, due = DueSlot(schedule, time.Date(2020, 7, 1, 0, 15, 0, 0, time.UTC))
+	assert.True(t, due)
+	assert.Equal(t, time.Date(2020, 6, 30, 23, 45, 0, 0, time.UTC), slot)
+
+	_, due = DueSlot(Schedule{At: []string{"02:30"}, Window: 5}, time.Date(2020, 6, 30, 2, 40, 0, 0, time.UTC))
+	assert.False(t, due)
+
+	_, due = DueSlot(Schedule{At: []string{"2h30"}}, time.Date(2020, 6, 30, 2, 40, 0, 0, time.UTC))
+	assert.False(t, due)
+}
+
+func TestScheduledIdentifier(t *testing.T) {
+	id := ScheduledIdentifier("rdscheck", time.Date(2020, 6, 30, 2, 30, 0, 0, time.UTC))
+	assert.Equal(t, "rdscheck-scheduled-rdscheck-2020-06-30-0230", id)
+
+	assert.True(t, IsScheduled(&rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String(id),
+		SnapshotType:         aws.String("manual"),
+	}))
+	assert.False(t, IsScheduled(&rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:rdscheck-2020-06-30-02-30"),
+		SnapshotType:         aws.String("automated"),
+	}))
+	assert.Equal(t, "scheduled", sourceType(&rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String(id),
+		SnapshotType:         aws.String("manual"),
+	}))
+}
+
+func TestCreateScheduledSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("CreateDBSnapshot", mock.MatchedBy(func(input *rds.CreateDBSnapshotInput) bool {
+		return *input.DBInstanceIdentifier == "rdscheck" &&
+			*input.DBSnapshotIdentifier == "rdscheck-scheduled-rdscheck-2020-06-30-0230" &&
+			*input.Tags[0].Key == "ScheduledBy"
+	})).Return(&rds.CreateDBSnapshotOutput{}, nil)
+
+	err := c.CreateScheduledSnapshot("rdscheck", "rdscheck-scheduled-rdscheck-2020-06-30-0230")
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
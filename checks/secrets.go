@@ -0,0 +1,143 @@
+package checks
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	log "github.com/sirupsen/logrus"
+)
+
+// passwordChars are the characters used in generated passwords.
+// RDS doesn't allow /, @, " or spaces in master passwords so we stick to alphanumeric characters.
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// passwordLength is the length of the generated passwords
+const passwordLength = 32
+
+// runSecretName returns the name of the secret holding the password generated for a restored instance
+func runSecretName(snapshot *rds.DBSnapshot) string {
+	return "rdscheck/" + *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier
+}
+
+// GetPassword resolves a password reference.
+// The reference is either secretsmanager:<secret id> or ssm:<parameter name>.
+// A secret holding json (like the secrets managed by RDS) returns its password key.
+func (c *Client) GetPassword(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "secretsmanager:"):
+		o, err := c.SecretsManager.GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(strings.TrimPrefix(ref, "secretsmanager:")),
+		})
+		if err != nil {
+			return "", err
+		}
+		if o.SecretString == nil {
+			return "", fmt.Errorf("Secret %s has no string value", ref)
+		}
+		var secret struct {
+			Password string `json:"password"`
+		}
+		if json.Unmarshal([]byte(*o.SecretString), &secret) == nil && secret.Password != "" {
+			AddSecret(secret.Password)
+			return secret.Password, nil
+		}
+		AddSecret(*o.SecretString)
+		return *o.SecretString, nil
+	case strings.HasPrefix(ref, "ssm:"):
+		o, err := c.SSM.GetParameter(&ssm.GetParameterInput{
+			Name:           aws.String(strings.TrimPrefix(ref, "ssm:")),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", err
+		}
+		AddSecret(*o.Parameter.Value)
+		return *o.Parameter.Value, nil
+	default:
+		return "", fmt.Errorf("Unsupported password reference %q", ref)
+	}
+}
+
+// CreateRunPassword generates a random password for a restored instance
+// and stores it in a secret until the instance is cleaned
+func (c *Client) CreateRunPassword(snapshot *rds.DBSnapshot) (string, error) {
+	password, err := randomPassword()
+	if err != nil {
+		return "", err
+	}
+	AddSecret(password)
+
+	_, err = c.SecretsManager.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(runSecretName(snapshot)),
+		SecretString: aws.String(password),
+		Tags: []*secretsmanager.Tag{
+			{
+				Key:   aws.String("CreatedBy"),
+				Value: aws.String("rdscheck"),
+			},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceExistsException {
+		_, err = c.SecretsManager.PutSecretValue(&secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(runSecretName(snapshot)),
+			SecretString: aws.String(password),
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// GetRunPassword returns the password generated for a restored instance
+func (c *Client) GetRunPassword(snapshot *rds.DBSnapshot) (string, error) {
+	o, err := c.SecretsManager.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(runSecretName(snapshot)),
+	})
+	if err != nil {
+		return "", err
+	}
+	AddSecret(*o.SecretString)
+	return *o.SecretString, nil
+}
+
+// DeleteRunPassword deletes the secret holding the password generated for a restored instance
+func (c *Client) DeleteRunPassword(snapshot *rds.DBSnapshot) error {
+	_, err := c.SecretsManager.DeleteSecret(&secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(runSecretName(snapshot)),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil
+		}
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Secret": runSecretName(snapshot),
+	}).Info("Secret deleted")
+	return nil
+}
+
+// randomPassword generates a random alphanumeric password
+func randomPassword() (string, error) {
+	password := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,158 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	mock.Mock
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	mock.Mock
+}
+
+func (m *mockSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*secretsmanager.GetSecretValueOutput), args.Error(1)
+}
+
+func (m *mockSecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*secretsmanager.CreateSecretOutput), args.Error(1)
+}
+
+func (m *mockSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*secretsmanager.PutSecretValueOutput), args.Error(1)
+}
+
+func (m *mockSecretsManager) DeleteSecret(input *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*secretsmanager.DeleteSecretOutput), args.Error(1)
+}
+
+func (m *mockSSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*ssm.GetParameterOutput), args.Error(1)
+}
+
+var secretSnapshot = &rds.DBSnapshot{
+	DBInstanceIdentifier: aws.String("test"),
+	DBSnapshotIdentifier: aws.String("snapshot"),
+}
+
+func TestGetPasswordSecretsManager(t *testing.T) {
+	mockSecretsManager := &mockSecretsManager{}
+	c := &Client{
+		SecretsManager: mockSecretsManager,
+	}
+
+	mockSecretsManager.On("GetSecretValue", &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("rds/test"),
+	}).Return(&secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String(`{"username": "postgres", "password": "fromjson"}`),
+	}, nil).Once()
+	mockSecretsManager.On("GetSecretValue", &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("plain"),
+	}).Return(&secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String("plaintext"),
+	}, nil).Once()
+
+	value, err := c.GetPassword("secretsmanager:rds/test")
+	assert.Nil(t, err)
+	assert.Equal(t, value, "fromjson")
+
+	value, err = c.GetPassword("secretsmanager:plain")
+	assert.Nil(t, err)
+	assert.Equal(t, value, "plaintext")
+}
+
+func TestGetPasswordSSM(t *testing.T) {
+	mockSSM := &mockSSM{}
+	c := &Client{
+		SSM: mockSSM,
+	}
+
+	mockSSM.On("GetParameter", &ssm.GetParameterInput{
+		Name:           aws.String("/rdscheck/password"),
+		WithDecryption: aws.Bool(true),
+	}).Return(&ssm.GetParameterOutput{
+		Parameter: &ssm.Parameter{
+			Value: aws.String("fromssm"),
+		},
+	}, nil)
+
+	value, err := c.GetPassword("ssm:/rdscheck/password")
+	assert.Nil(t, err)
+	assert.Equal(t, value, "fromssm")
+}
+
+func TestGetPasswordUnsupported(t *testing.T) {
+	c := &Client{}
+
+	_, err := c.GetPassword("vault:secret/rdscheck")
+	assert.NotNil(t, err)
+}
+
+func TestCreateRunPassword(t *testing.T) {
+	mockSecretsManager := &mockSecretsManager{}
+	c := &Client{
+		SecretsManager: mockSecretsManager,
+	}
+
+	mockSecretsManager.On("CreateSecret", mock.MatchedBy(func(input *secretsmanager.CreateSecretInput) bool {
+		return *input.Name == "rdscheck/test-snapshot"
+	})).Return(&secretsmanager.CreateSecretOutput{}, awserr.New(secretsmanager.ErrCodeResourceExistsException, "", nil))
+	mockSecretsManager.On("PutSecretValue", mock.Anything).Return(&secretsmanager.PutSecretValueOutput{}, nil)
+
+	value, err := c.CreateRunPassword(secretSnapshot)
+	assert.Nil(t, err)
+	assert.Len(t, value, passwordLength)
+	assert.Regexp(t, "^[a-zA-Z0-9]+$", value)
+	mockSecretsManager.AssertExpectations(t)
+}
+
+func TestGetRunPassword(t *testing.T) {
+	mockSecretsManager := &mockSecretsManager{}
+	c := &Client{
+		SecretsManager: mockSecretsManager,
+	}
+
+	mockSecretsManager.On("GetSecretValue", &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("rdscheck/test-snapshot"),
+	}).Return(&secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String("generated"),
+	}, nil)
+
+	value, err := c.GetRunPassword(secretSnapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, value, "generated")
+}
+
+func TestDeleteRunPassword(t *testing.T) {
+	mockSecretsManager := &mockSecretsManager{}
+	c := &Client{
+		SecretsManager: mockSecretsManager,
+	}
+
+	mockSecretsManager.On("DeleteSecret", &secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String("rdscheck/test-snapshot"),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	}).Return(&secretsmanager.DeleteSecretOutput{}, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "", nil))
+
+	err := c.DeleteRunPassword(secretSnapshot)
+	assert.Nil(t, err)
+}
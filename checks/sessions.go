@@ -0,0 +1,46 @@
+package checks
+
+import (
+	"strings"
+
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+// SourceRegion returns the region of the instance, AWS_REGION_SOURCE by default
+func SourceRegion(instance Instances) string {
+	if instance.SourceRegion != "" {
+		return instance.SourceRegion
+	}
+	return config.AWSRegionSource
+}
+
+// SetSourceSessions points c to the source region and account of an instance
+func SetSourceSessions(c DefaultChecks, instance Instances) {
+	setInstanceSessions(c, SourceRegion(instance), instance.RoleArn)
+}
+
+// SetDestinationSessions points c to the destination region in the account of an instance.
+// The datadog checks and metrics of an instance with several destinations are tagged with the region.
+func SetDestinationSessions(c DefaultChecks, instance Instances) {
+	setInstanceSessions(c, instance.Destination, instance.RoleArn)
+	if instance.MultiDestination {
+		c.SetRegionTag(true)
+	}
+}
+
+func setInstanceSessions(c DefaultChecks, region, roleArn string) {
+	if roleArn == "" {
+		c.SetSessions(region)
+		return
+	}
+	c.SetRoleSessions(region, roleArn)
+}
+
+// snapshotRegion returns the region of a snapshot arn, AWS_REGION_SOURCE if it can't be parsed
+func snapshotRegion(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 4 || parts[3] == "" {
+		return config.AWSRegionSource
+	}
+	return parts[3]
+}
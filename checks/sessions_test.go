@@ -0,0 +1,57 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+func TestSourceRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", SourceRegion(Instances{SourceRegion: "eu-west-1"}))
+	assert.Equal(t, config.AWSRegionSource, SourceRegion(Instances{}))
+}
+
+func TestSnapshotRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", snapshotRegion("arn:aws:rds:eu-west-1:123456789012:snapshot:rds:test"))
+	assert.Equal(t, config.AWSRegionSource, snapshotRegion("test"))
+}
+
+func TestCopySnapshotsSourceRegion(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:eu-west-1:123456789012:snapshot:test"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
+		Encrypted:            aws.Bool(false),
+	}
+
+	rdsc.On("CopyDBSnapshot", mock.MatchedBy(func(input *rds.CopyDBSnapshotInput) bool {
+		return *input.SourceRegion == "eu-west-1" && *input.DestinationRegion == "us-east-1"
+	})).Return(&rds.CopyDBSnapshotOutput{}, nil)
+
+	err := c.CopySnapshots(input, "us-east-1", "", "", "test", false)
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
+func TestSetDestinationSessionsRegionTag(t *testing.T) {
+	c := &Client{}
+
+	SetDestinationSessions(c, Instances{Destination: "eu-west-1", MultiDestination: true})
+	assert.Equal(t, "eu-west-1", c.Region)
+	assert.True(t, c.RegionTag)
+
+	SetDestinationSessions(c, Instances{Destination: "us-east-1"})
+	assert.Equal(t, "us-east-1", c.Region)
+	assert.False(t, c.RegionTag)
+}
@@ -0,0 +1,52 @@
+package checks
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// SnapshotTypes returns the types of snapshots to copy for an instance, automated by default
+func SnapshotTypes(instance Instances) []string {
+	if len(instance.SnapshotTypes) == 0 {
+		return []string{"automated"}
+	}
+	return instance.SnapshotTypes
+}
+
+// CopiesType tells if the snapshots of type snapshotType are copied for an instance
+func CopiesType(instance Instances, snapshotType string) bool {
+	for _, t := range SnapshotTypes(instance) {
+		if t == snapshotType {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchManual tells if a manual snapshot passes the name and tag filters of an instance.
+// The tag filter is written key=value.
+func (c *Client) MatchManual(snapshot *rds.DBSnapshot, manual Manual) bool {
+	if !strings.HasPrefix(*snapshot.DBSnapshotIdentifier, manual.Prefix) {
+		return false
+	}
+	if manual.Tag == "" {
+		return true
+	}
+	tag := strings.SplitN(manual.Tag, "=", 2)
+	if len(tag) != 2 {
+		return false
+	}
+	return c.CheckTag(*snapshot.DBSnapshotArn, tag[0], tag[1])
+}
+
+// sourceType returns the type of the snapshot a copy comes from
+func sourceType(snapshot *rds.DBSnapshot) string {
+	if snapshot.SnapshotType == nil {
+		return "automated"
+	}
+	if IsScheduled(snapshot) {
+		return "scheduled"
+	}
+	return *snapshot.SnapshotType
+}
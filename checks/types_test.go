@@ -0,0 +1,76 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCopiesType(t *testing.T) {
+	assert.True(t, CopiesType(Instances{}, "automated"))
+	assert.False(t, CopiesType(Instances{}, "manual"))
+	assert.True(t, CopiesType(Instances{SnapshotTypes: []string{"automated", "manual"}}, "manual"))
+	assert.False(t, CopiesType(Instances{SnapshotTypes: []string{"manual"}}, "automated"))
+}
+
+func TestMatchManual(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	snapshot := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("pre-migration-1"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:pre-migration-1"),
+	}
+
+	rdsc.On("ListTagsForResource", mock.Anything).Return(&rds.ListTagsForResourceOutput{
+		TagList: []*rds.Tag{
+			&rds.Tag{
+				Key:   aws.String("Backup"),
+				Value: aws.String("yes"),
+			},
+		},
+	}, nil)
+
+	assert.True(t, c.MatchManual(snapshot, Manual{}))
+	assert.True(t, c.MatchManual(snapshot, Manual{Prefix: "pre-migration-"}))
+	assert.False(t, c.MatchManual(snapshot, Manual{Prefix: "nightly-"}))
+	assert.True(t, c.MatchManual(snapshot, Manual{Prefix: "pre-migration-", Tag: "Backup=yes"}))
+	assert.False(t, c.MatchManual(snapshot, Manual{Tag: "Backup=no"}))
+	assert.False(t, c.MatchManual(snapshot, Manual{Tag: "Backup"}))
+}
+
+func TestCopySnapshotsSourceType(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	input := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("pre-migration-1"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:pre-migration-1"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
+		SnapshotType:         aws.String("manual"),
+		Encrypted:            aws.Bool(false),
+	}
+
+	rdsc.On("CopyDBSnapshot", mock.MatchedBy(func(input *rds.CopyDBSnapshotInput) bool {
+		for _, tag := range input.Tags {
+			if *tag.Key == "SourceType" {
+				return *tag.Value == "manual"
+			}
+		}
+		return false
+	})).Return(&rds.CopyDBSnapshotOutput{}, nil)
+
+	err := c.CopySnapshots(input, "us-east-1", "", "", "pre-migration-1", false)
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
@@ -0,0 +1,132 @@
+package checks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// SetVaultSessions points c to where the copies of an instance live:
+// the vault account if the instance has one, otherwise its destination
+func SetVaultSessions(c DefaultChecks, instance Instances) {
+	if instance.Vault.RoleArn == "" {
+		SetDestinationSessions(c, instance)
+		return
+	}
+	c.SetRoleSessions(instance.Destination, instance.Vault.RoleArn)
+	if instance.MultiDestination {
+		c.SetRegionTag(true)
+	}
+}
+
+// VaultAccount returns the aws account id of a vault role arn
+func VaultAccount(roleArn string) string {
+	arn := strings.Split(roleArn, ":")
+	if len(arn) < 5 {
+		return ""
+	}
+	return arn[4]
+}
+
+// VaultStagingIdentifier returns the identifier of the copy shared with the vault account
+func VaultStagingIdentifier(cleanArn string) string {
+	return cleanArn + "-vault"
+}
+
+// GetSnapshot returns a snapshot or nil if it doesn't exist
+func (c *Client) GetSnapshot(DBSnapshotIdentifier string) (*rds.DBSnapshot, error) {
+	input := &rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String(DBSnapshotIdentifier),
+	}
+	o, err := c.RDS.DescribeDBSnapshots(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBSnapshotNotFoundFault {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, snapshot := range o.DBSnapshots {
+		return snapshot, nil
+	}
+	return nil, nil
+}
+
+// UnshareSnapshot removes the restore permission of other aws accounts on a manual snapshot
+func (c *Client) UnshareSnapshot(DBSnapshotIdentifier string, accounts []string) error {
+	input := &rds.ModifyDBSnapshotAttributeInput{
+		AttributeName:        aws.String("restore"),
+		DBSnapshotIdentifier: aws.String(DBSnapshotIdentifier),
+		ValuesToRemove:       aws.StringSlice(accounts),
+	}
+	_, err := c.RDS.ModifyDBSnapshotAttribute(input)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Snapshot": DBSnapshotIdentifier,
+		"Accounts": accounts,
+	}).Info("Snapshot unshared")
+	return nil
+}
+
+// CopyVaultSnapshot copies a snapshot shared by the source account into the vault account.
+// The copy is encrypted with the vault kms key and tagged for the check state machine.
+func (c *Client) CopyVaultSnapshot(snapshot, staging *rds.DBSnapshot, kmsid, cleanArn string) error {
+	input := &rds.CopyDBSnapshotInput{
+		SourceDBSnapshotIdentifier: aws.String(*staging.DBSnapshotArn),
+		TargetDBSnapshotIdentifier: aws.String(cleanArn),
+		Tags: []*rds.Tag{
+			{
+				Key:   aws.String("CreatedBy"),
+				Value: aws.String("rdscheck"),
+			},
+			{
+				Key:   aws.String("RDS Instance"),
+				Value: aws.String(*snapshot.DBSnapshotIdentifier),
+			},
+			{
+				Key:   aws.String("Status"),
+				Value: aws.String("ready"),
+			},
+			{
+				Key:   aws.String("ChecksFailed"),
+				Value: aws.String("no"),
+			},
+			{
+				Key:   aws.String("SourceType"),
+				Value: aws.String(sourceType(snapshot)),
+			},
+			{
+				Key:   aws.String("SourceCreateTime"),
+				Value: aws.String(snapshot.SnapshotCreateTime.UTC().Format(time.RFC3339)),
+			},
+		},
+	}
+
+	if kmsid != "" {
+		input.KmsKeyId = aws.String(kmsid)
+	}
+
+	_, err := c.RDS.CopyDBSnapshot(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == rds.ErrCodeDBSnapshotAlreadyExistsFault {
+			log.WithFields(log.Fields{
+				"Snapshot": *snapshot.DBSnapshotIdentifier,
+			}).Info("Snapshot already exist in the vault")
+			return nil
+		}
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"Snapshot": *snapshot.DBSnapshotIdentifier,
+		"Staging":  *staging.DBSnapshotIdentifier,
+		"Vault":    c.Region,
+	}).Info("Snapshot copied to the vault")
+	return nil
+}
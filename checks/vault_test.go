@@ -0,0 +1,104 @@
+package checks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVaultAccount(t *testing.T) {
+	assert.Equal(t, "999999999999", VaultAccount("arn:aws:iam::999999999999:role/rdscheck-vault"))
+	assert.Equal(t, "", VaultAccount("rdscheck-vault"))
+}
+
+func TestGetSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBSnapshots", &rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String("test-vault"),
+	}).Return(&rds.DescribeDBSnapshotsOutput{
+		DBSnapshots: []*rds.DBSnapshot{
+			&rds.DBSnapshot{
+				DBSnapshotIdentifier: aws.String("test-vault"),
+				Status:               aws.String("available"),
+			},
+		},
+	}, nil)
+
+	value, err := c.GetSnapshot("test-vault")
+	assert.Nil(t, err)
+	assert.Equal(t, "available", *value.Status)
+	rdsc.AssertExpectations(t)
+}
+
+func TestGetSnapshotNotFound(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("DescribeDBSnapshots", mock.Anything).Return(&rds.DescribeDBSnapshotsOutput{}, awserr.New(rds.ErrCodeDBSnapshotNotFoundFault, "not found", nil))
+
+	value, err := c.GetSnapshot("test-vault")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	rdsc.AssertExpectations(t)
+}
+
+func TestUnshareSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	rdsc.On("ModifyDBSnapshotAttribute", &rds.ModifyDBSnapshotAttributeInput{
+		AttributeName:        aws.String("restore"),
+		DBSnapshotIdentifier: aws.String("test-vault"),
+		ValuesToRemove:       aws.StringSlice([]string{"999999999999"}),
+	}).Return(&rds.ModifyDBSnapshotAttributeOutput{}, nil)
+
+	err := c.UnshareSnapshot("test-vault", []string{"999999999999"})
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
+
+func TestCopyVaultSnapshot(t *testing.T) {
+	rdsc := &mockRDS{}
+
+	c := &Client{
+		RDS: rdsc,
+	}
+
+	snapshot := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2019-01-01"),
+		SnapshotCreateTime:   aws.Time(time.Date(2019, 1, 1, 3, 0, 0, 0, time.UTC)),
+	}
+	staging := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("test-2019-01-01-vault"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:test-2019-01-01-vault"),
+	}
+
+	rdsc.On("CopyDBSnapshot", mock.MatchedBy(func(input *rds.CopyDBSnapshotInput) bool {
+		return *input.SourceDBSnapshotIdentifier == *staging.DBSnapshotArn &&
+			*input.TargetDBSnapshotIdentifier == "test-2019-01-01" &&
+			*input.KmsKeyId == "vault-key" &&
+			input.SourceRegion == nil &&
+			*input.Tags[5].Key == "SourceCreateTime" &&
+			*input.Tags[5].Value == "2019-01-01T03:00:00Z"
+	})).Return(&rds.CopyDBSnapshotOutput{}, nil)
+
+	err := c.CopyVaultSnapshot(snapshot, staging, "vault-key", "test-2019-01-01")
+	assert.Nil(t, err)
+	rdsc.AssertExpectations(t)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/checks"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+// Event selects the golden files to accept.
+// An empty golden accepts all the golden files of the instance.
+type Event struct {
+	Instance string `json:"instance"`
+	Golden   string `json:"golden"`
+}
+
+func main() {
+	log.AddHook(&checks.RedactHook{})
+	lambda.Start(run)
+}
+
+func run(event Event) {
+	checks.ResetPlan()
+
+	source := checks.New()
+	destination := checks.New()
+
+	doc, err := getDoc(source)
+	if err != nil {
+		log.WithError(err).Error("getDoc returned:")
+		os.Exit(1)
+	}
+
+	err = accept(destination, doc, event)
+	if err != nil {
+		log.WithError(err).Error("accept returned:")
+		os.Exit(1)
+	}
+
+	checks.LogPlan()
+}
+
+func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
+	source.SetSessions(config.AWSRegionSource)
+
+	doc := checks.Doc{}
+
+	yaml, err := source.GetYamlFileFromS3(config.S3Bucket, config.S3Key)
+	if err != nil {
+		log.WithError(err).Error("Could not get the yaml file from s3")
+		return doc, err
+	}
+
+	doc, err = source.UnmarshalYamlFile(yaml)
+	if err != nil {
+		log.WithError(err).Error("Could not unmarshal yaml file")
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+// accept replaces the golden files of an instance with the results of its last failed check
+func accept(destination checks.DefaultChecks, doc checks.Doc, event Event) error {
+	for _, instance := range doc.Instances {
+		if instance.Name != event.Instance {
+			continue
+		}
+		checks.SetVaultSessions(destination, instance)
+
+		for _, query := range instance.Queries {
+			if query.Golden == "" || (event.Golden != "" && query.Golden != event.Golden) {
+				continue
+			}
+			key := checks.GoldenKey(instance.Golden, checks.InstanceKey(instance), query.Golden)
+			err := destination.AcceptGolden(instance.Golden.Bucket, key)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"RDS Instance": instance.Name,
+					"Bucket":       instance.Golden.Bucket,
+					"Key":          key,
+				}).WithError(err).Error("Could not accept golden file")
+				return err
+			}
+		}
+	}
+	return nil
+}
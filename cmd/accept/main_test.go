@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/techdroplabs/rdscheck/checks"
+)
+
+type mockDefaultChecks struct {
+	checks.DefaultChecks
+	mock.Mock
+}
+
+var doc = checks.Doc{
+	Instances: []checks.Instances{
+		checks.Instances{
+			Name:        "test",
+			Destination: "us-east-1",
+			Golden: checks.Golden{
+				Bucket: "golden",
+				Prefix: "golden/",
+			},
+			Queries: []checks.Queries{
+				checks.Queries{
+					Query:  "SELECT id FROM users",
+					Golden: "users.csv",
+				},
+				checks.Queries{
+					Query:  "SELECT id FROM orders",
+					Golden: "orders.json",
+				},
+				checks.Queries{
+					Query: "SELECT tablename FROM pg_catalog.pg_tables;",
+					Regex: "^pg_statistic$",
+				},
+			},
+		},
+	},
+}
+
+func (m *mockDefaultChecks) SetSessions(region string) {
+	m.Called(region)
+}
+
+func (m *mockDefaultChecks) GetYamlFileFromS3(bucket string, key string) (io.Reader, error) {
+	args := m.Called(bucket, key)
+	return args.Get(0).(io.Reader), args.Error(1)
+}
+
+func (m *mockDefaultChecks) UnmarshalYamlFile(body io.Reader) (checks.Doc, error) {
+	args := m.Called(body)
+	return args.Get(0).(checks.Doc), args.Error(1)
+}
+
+func (m *mockDefaultChecks) AcceptGolden(bucket, key string) error {
+	args := m.Called(bucket, key)
+	return args.Error(0)
+}
+
+func TestGetDoc(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	yaml, _ := ioutil.ReadFile("../../example/checks.yml")
+	input := bytes.NewReader(yaml)
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetYamlFileFromS3", mock.Anything, mock.Anything).Return(input, nil)
+	c.On("UnmarshalYamlFile", mock.Anything).Return(doc, nil)
+
+	value, err := getDoc(c)
+
+	assert.Nil(t, err)
+	assert.Equal(t, value, doc)
+}
+
+func TestAccept(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetSessions", "us-east-1").Return()
+	c.On("AcceptGolden", "golden", "golden/test/users.csv").Return(nil)
+	c.On("AcceptGolden", "golden", "golden/test/orders.json").Return(nil)
+
+	err := accept(c, doc, Event{Instance: "test"})
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestAcceptSingleFile(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetSessions", "us-east-1").Return()
+	c.On("AcceptGolden", "golden", "golden/test/orders.json").Return(nil)
+
+	err := accept(c, doc, Event{Instance: "test", Golden: "orders.json"})
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+	c.AssertNumberOfCalls(t, "AcceptGolden", 1)
+}
+
+func TestAcceptUnknownInstance(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	err := accept(c, doc, Event{Instance: "unknown"})
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "AcceptGolden", mock.Anything, mock.Anything)
+}
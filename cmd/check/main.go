@@ -2,12 +2,17 @@ package main
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
 	log "github.com/sirupsen/logrus"
 	"github.com/techdroplabs/rdscheck/checks"
 	"github.com/techdroplabs/rdscheck/config"
+	"github.com/techdroplabs/rdscheck/utils"
 )
 
 const (
@@ -18,13 +23,76 @@ const (
 	Clean   = "clean"
 	Tested  = "tested"
 	Alarm   = "alarm"
+	// Optional states, see stages
+	Report    = "report"
+	Export    = "export"
+	Sanitize  = "sanitize"
+	Sharing   = "sharing"
+	Upgrade   = "upgrade"
+	Upgrading = "upgrading"
 )
 
+// stages are the optional states a snapshot goes through after verify and before clean
+// if they are configured for the instance.
+// Sanitize rewrites the restored database so it runs last, after the stages reading the data.
+var stages = []struct {
+	status  string
+	enabled func(instance *checks.Instances) bool
+}{
+	{Report, func(instance *checks.Instances) bool { return instance.Report.Bucket != "" }},
+	{Export, func(instance *checks.Instances) bool { return len(instance.Jobs) > 0 }},
+	{Upgrade, func(instance *checks.Instances) bool { return instance.UpgradeTo != "" }},
+	{Sanitize, func(instance *checks.Instances) bool { return instance.Sanitize.Script != "" }},
+}
+
+// nextState returns the state following status, skipping the stages
+// that are not configured for the instance
+func nextState(instance *checks.Instances, status string) string {
+	found := status == Verify
+	for _, stage := range stages {
+		if found && stage.enabled(instance) {
+			return stage.status
+		}
+		if stage.status == status {
+			found = true
+		}
+	}
+	return Clean
+}
+
+// runPasswordStates are the states connecting to the restored database.
+// The password generated for the restore is loaded before running them,
+// and they are skipped in dry run mode.
+var runPasswordStates = map[string]bool{
+	Verify:    true,
+	Report:    true,
+	Export:    true,
+	Sanitize:  true,
+	Upgrading: true,
+}
+
+// dryRunSkipped tells if a state is skipped in dry run mode: the states connecting
+// to the restored database, and modify whose password reset never happens.
+func dryRunSkipped(status string) bool {
+	return runPasswordStates[status] || status == Modify
+}
+
+// rtoTags are the snapshot tags where we store how many seconds each step of a restore took
+var rtoTags = map[string]string{
+	"available":      "RTOAvailable",
+	"password_reset": "RTOPasswordReset",
+	"first_query":    "RTOFirstQuery",
+	"total":          "RTOTotal",
+}
+
 func main() {
+	log.AddHook(&checks.RedactHook{})
 	lambda.Start(run)
 }
 
 func run() {
+	checks.ResetPlan()
+
 	source := checks.New()
 	destination := checks.New()
 
@@ -38,6 +106,13 @@ func run() {
 	if err != nil {
 		log.WithError(err).Error("Could not validate the snapshots")
 	}
+
+	err = validatePointInTime(source, doc)
+	if err != nil {
+		log.WithError(err).Error("Could not validate the point in time restores")
+	}
+
+	checks.LogPlan()
 }
 
 func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
@@ -56,12 +131,29 @@ func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
 		log.WithError(err).Error("Could not unmarshal yaml file")
 		return doc, err
 	}
+
+	for i, instance := range doc.Instances {
+		if instance.PasswordRef == "" {
+			continue
+		}
+		doc.Instances[i].Password, err = source.GetPassword(instance.PasswordRef)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"Password Ref": instance.PasswordRef,
+			}).WithError(err).Error("Could not resolve the password")
+			return doc, err
+		}
+	}
 	return doc, nil
 }
 
 func validate(destination checks.DefaultChecks, doc checks.Doc) error {
 	for _, instance := range doc.Instances {
-		destination.SetSessions(instance.Destination)
+		if instance.SkipChecks {
+			continue
+		}
+		checks.SetVaultSessions(destination, instance)
 		snapshots, err := destination.GetSnapshots(instance.Name)
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -82,11 +174,123 @@ func validate(destination checks.DefaultChecks, doc checks.Doc) error {
 				}
 			}
 		}
+
+		err = cleanSanitized(destination, &instance)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+			}).WithError(err).Error("Could not clean the sanitized snapshots")
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanSanitized deletes the sanitized snapshots older than the sanitize retention,
+// or than the instance retention if it is not set. They are kept if neither is set.
+func cleanSanitized(destination checks.DefaultChecks, instance *checks.Instances) error {
+	retention := instance.Sanitize.Retention
+	if retention == 0 {
+		retention = instance.Retention
+	}
+	if instance.Sanitize.Script == "" || retention == 0 {
+		return nil
+	}
+
+	sanitized, err := destination.GetSanitizedSnapshots(instance.Name)
+	if err != nil {
+		return err
+	}
+	oldSnapshots, err := destination.GetOldSnapshots(sanitized, retention)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range oldSnapshots {
+		err := destination.DeleteOldSnapshot(snapshot)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePointInTime restores the instances to a random point in time every PITR.Interval hours
+// and runs the restored instance through the same states as the snapshots
+func validatePointInTime(source checks.DefaultChecks, doc checks.Doc) error {
+	for _, instance := range doc.Instances {
+		if instance.PITR.Interval <= 0 {
+			continue
+		}
+		checks.SetSourceSessions(source, instance)
+
+		snapshot, err := source.GetPointInTimeRestore(instance.Name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+			}).WithError(err).Error("Could not get point in time restore")
+			return err
+		}
+
+		if snapshot == nil {
+			if !source.PointInTimeRestoreDue(instance.Name, instance.PITR.Interval) {
+				continue
+			}
+			subnets, securityGroups := checks.PointInTimeNetwork(instance.PITR)
+			_, err := source.CreateDBFromPointInTime(instance.Name, instance.Type, securityGroups, subnets, instance.IAMAuth)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"RDS Instance": instance.Name,
+				}).WithError(err).Error("Could not restore rds instance to a point in time")
+
+				pitr := &rds.DBSnapshot{
+					DBInstanceIdentifier: aws.String(instance.Name),
+					DBSnapshotIdentifier: aws.String("pitr"),
+				}
+				errors := source.PostDatadogChecks(pitr, "rdscheck.status", "critical", "check")
+				if errors != nil {
+					log.WithError(errors).Error("Could not update datadog status")
+				}
+				return err
+			}
+			continue
+		}
+
+		status := source.GetTagValue(*snapshot.DBSnapshotArn, "Status")
+		err = process(source, snapshot, &instance, status)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"Snapshot":     *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not validate point in time restore")
+			return err
+		}
 	}
 	return nil
 }
 
 func process(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances, status string) error {
+	if config.DryRun && dryRunSkipped(status) {
+		checks.Planned("State "+status, *snapshot.DBInstanceIdentifier+"-"+*snapshot.DBSnapshotIdentifier)
+		return nil
+	}
+
+	if instance.RandomPassword && !instance.IAMAuth && runPasswordStates[status] {
+		password, err := destination.GetRunPassword(snapshot)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not get the generated password")
+			errors := destination.UpdateTag(snapshot, "Status", "alarm")
+			if errors != nil {
+				return err
+			}
+			return err
+		}
+		run := *instance
+		run.Password = password
+		instance = &run
+	}
+
 	switch status {
 	case Ready:
 		return caseReady(destination, snapshot)
@@ -96,10 +300,22 @@ func process(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instanc
 		return caseModify(destination, snapshot, instance)
 	case Verify:
 		return caseVerify(destination, snapshot, instance)
+	case Report:
+		return caseReport(destination, snapshot, instance)
+	case Export:
+		return caseExport(destination, snapshot, instance)
+	case Sanitize:
+		return caseSanitize(destination, snapshot, instance)
+	case Sharing:
+		return caseSharing(destination, snapshot, instance)
+	case Upgrade:
+		return caseUpgrade(destination, snapshot, instance)
+	case Upgrading:
+		return caseUpgrading(destination, snapshot, instance)
 	case Alarm:
 		return caseAlarm(destination, snapshot)
 	case Clean:
-		return caseClean(destination, snapshot)
+		return caseClean(destination, snapshot, instance)
 	case Tested:
 		return caseTested(destination, snapshot)
 	default:
@@ -133,7 +349,7 @@ func caseReady(destination checks.DefaultChecks, snapshot *rds.DBSnapshot) error
 }
 
 func caseRestore(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
-	err := destination.CreateDBFromSnapshot(snapshot, instance.Type, config.SecurityGroupIds)
+	err := destination.CreateDBFromSnapshot(snapshot, instance.Type, config.SecurityGroupIds, instance.IAMAuth)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"Snapshot":     *snapshot.DBSnapshotIdentifier,
@@ -146,7 +362,18 @@ func caseRestore(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, ins
 		return err
 	}
 
-	err = destination.UpdateTag(snapshot, "Status", "modify")
+	err = destination.UpdateTag(snapshot, "RestoreStartTime", utils.GetUnixTimeAsString())
+	if err != nil {
+		return err
+	}
+
+	// IAM authentication is enabled by the restore so there is no password to reset
+	status := Modify
+	if instance.IAMAuth {
+		status = Verify
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", status)
 	if err != nil {
 		return err
 	}
@@ -158,6 +385,8 @@ func caseModify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 		return nil
 	}
 
+	recordRTO(destination, snapshot, "available")
+
 	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -170,7 +399,22 @@ func caseModify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 		return err
 	}
 
-	err = destination.ChangeDBpassword(snapshot, *dbInfo.DBInstanceArn, instance.Password)
+	password := instance.Password
+	if instance.RandomPassword {
+		password, err = destination.CreateRunPassword(snapshot)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not generate a password")
+			errors := destination.UpdateTag(snapshot, "Status", "alarm")
+			if errors != nil {
+				return err
+			}
+			return err
+		}
+	}
+
+	err = destination.ChangeDBpassword(snapshot, *dbInfo.DBInstanceArn, password)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
@@ -194,6 +438,12 @@ func caseVerify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 		return nil
 	}
 
+	if instance.IAMAuth {
+		recordRTO(destination, snapshot, "available")
+	} else {
+		recordRTO(destination, snapshot, "password_reset")
+	}
+
 	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -206,7 +456,7 @@ func caseVerify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 		return err
 	}
 
-	err = destination.InitDb(dbInfo, instance.Password, instance.Database)
+	password, err := connect(destination, dbInfo, instance)
 	if err != nil {
 		errors := destination.UpdateTag(snapshot, "Status", "alarm")
 		if errors != nil {
@@ -215,13 +465,25 @@ func caseVerify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 		return err
 	}
 
+	rto, ok := recordRTO(destination, snapshot, "first_query")
+	if ok && instance.RTO > 0 {
+		status := "ok"
+		if rto > float64(instance.RTO*60) {
+			status = "critical"
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"RTO":          rto,
+				"RTO Target":   instance.RTO * 60,
+			}).Error("RTO target exceeded")
+		}
+		err = destination.PostDatadogChecks(snapshot, "rdscheck.rto", status, "check")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+		}
+	}
+
 	for _, query := range instance.Queries {
-		if destination.CheckRegexAgainstRow(query.Query, query.Regex) {
-			err := destination.UpdateTag(snapshot, "Status", "clean")
-			if err != nil {
-				return err
-			}
-		} else {
+		if hasRegex(query) && !destination.CheckRegexAgainstRow(query.Query, query.Regex) {
 			log.WithFields(log.Fields{
 				"RDS Instance": string(*snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier),
 				"DB Name":      *dbInfo.DBName,
@@ -234,6 +496,529 @@ func caseVerify(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, inst
 			}
 			return err
 		}
+
+		if query.Golden == "" {
+			continue
+		}
+		key := checks.GoldenKey(instance.Golden, checks.InstanceKey(*instance), query.Golden)
+		diff, err := destination.CompareGolden(query, instance.Golden.Bucket, key)
+		if err != nil || len(diff) > 0 {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Query":        query.Query,
+				"Golden":       instance.Golden.Bucket + "/" + key,
+				"Diff":         strings.Join(diff, "\n"),
+			}).WithError(err).Error("Query result doesn't match the golden file")
+			errors := destination.UpdateTag(snapshot, "Status", "alarm")
+			if errors != nil {
+				return err
+			}
+			return err
+		}
+	}
+
+	for _, hook := range instance.Hooks {
+		result, err := destination.RunHook(dbInfo, password, instance.Database, sslMode(instance), hook)
+
+		status := "ok"
+		if err != nil {
+			status = "critical"
+		}
+		errors := destination.PostDatadogCheckMessage(snapshot, "rdscheck.hook", status, result.Message(), "check", []string{"hook:" + checks.HookName(hook)})
+		if errors != nil {
+			log.WithError(errors).Error("Could not update datadog status")
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Command":      hook.Command,
+				"Exit Code":    result.ExitCode,
+				"Output":       result.Output,
+				"Stderr":       result.Stderr,
+			}).WithError(err).Error("Hook failed")
+			errors := destination.UpdateTag(snapshot, "Status", "alarm")
+			if errors != nil {
+				return err
+			}
+			return err
+		}
+		log.WithFields(log.Fields{
+			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			"Command":      hook.Command,
+			"Output":       result.Output,
+		}).Info("Hook succeeded")
+	}
+
+	for _, query := range instance.Benchmark.Queries {
+		result, err := destination.RunBenchmark(query.Query, instance.Benchmark.Warmup, instance.Benchmark.Iterations)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Query":        query.Query,
+			}).WithError(err).Error("Could not benchmark query")
+			errors := destination.UpdateTag(snapshot, "Status", "alarm")
+			if errors != nil {
+				return err
+			}
+			return err
+		}
+
+		name := checks.BenchmarkName(query)
+		tags := []string{"query:" + name}
+
+		err = destination.PostDatadogMetric(snapshot, "rdscheck.benchmark.p50", result.P50, "check", tags)
+		if err != nil {
+			log.WithError(err).Error("Could not post datadog metric")
+			return err
+		}
+
+		err = destination.PostDatadogMetric(snapshot, "rdscheck.benchmark.p95", result.P95, "check", tags)
+		if err != nil {
+			log.WithError(err).Error("Could not post datadog metric")
+			return err
+		}
+
+		p50Key, p95Key := checks.BenchmarkTags(name)
+		err = destination.UpdateTag(snapshot, p50Key, strconv.FormatFloat(result.P50, 'f', 2, 64))
+		if err != nil {
+			return err
+		}
+		err = destination.UpdateTag(snapshot, p95Key, strconv.FormatFloat(result.P95, 'f', 2, 64))
+		if err != nil {
+			return err
+		}
+
+		if (query.P50 > 0 && result.P50 > query.P50) || (query.P95 > 0 && result.P95 > query.P95) {
+			log.WithFields(log.Fields{
+				"RDS Instance":  *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Query":         query.Query,
+				"P50":           result.P50,
+				"P95":           result.P95,
+				"P50 Threshold": query.P50,
+				"P95 Threshold": query.P95,
+			}).Error("Benchmark latencies exceeded the thresholds")
+			err := destination.UpdateTag(snapshot, "Status", "alarm")
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+
+		if instance.Benchmark.Baseline <= 0 {
+			continue
+		}
+		baseline, ok := destination.GetBenchmarkBaseline(snapshot, name)
+		if ok && (result.P50 > baseline.P50*instance.Benchmark.Baseline || result.P95 > baseline.P95*instance.Benchmark.Baseline) {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Query":        query.Query,
+				"P50":          result.P50,
+				"P95":          result.P95,
+				"Baseline P50": baseline.P50,
+				"Baseline P95": baseline.P95,
+				"Ratio":        instance.Benchmark.Baseline,
+			}).Error("Benchmark latencies regressed from the previous run")
+			err := destination.UpdateTag(snapshot, "Status", "alarm")
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+
+	patterns := append(append([]string{}, instance.Logs.Fail...), instance.Logs.Warn...)
+	if len(patterns) > 0 {
+		matches, err := destination.ScanDBLogs(snapshot, patterns)
+		if err != nil {
+			// retrying would run the hooks and benchmarks again and fail the same way
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not scan the logs")
+			err := destination.PostDatadogCheckMessage(snapshot, "rdscheck.logs", "critical", err.Error(), "check", nil)
+			if err != nil {
+				log.WithError(err).Error("Could not update datadog status")
+				return err
+			}
+			return destination.UpdateTag(snapshot, "Status", Alarm)
+		}
+
+		status := "ok"
+		for _, match := range matches {
+			level := "warning"
+			for _, pattern := range instance.Logs.Fail {
+				if match.Pattern == pattern {
+					level = "critical"
+				}
+			}
+			if level == "critical" || status == "ok" {
+				status = level
+			}
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Log File":     match.File,
+				"Pattern":      match.Pattern,
+				"Line":         match.Line,
+			}).Warn("Found a match in the logs")
+		}
+
+		err = destination.PostDatadogChecks(snapshot, "rdscheck.logs", status, "check")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+
+		if status == "critical" {
+			err := destination.UpdateTag(snapshot, "Status", "alarm")
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+
+	// the stages would name their outputs after the pitr pseudo snapshot,
+	// shared by every instance, so point in time restores skip them
+	next := nextState(instance, Verify)
+	if checks.IsPointInTime(snapshot) {
+		next = Clean
+	}
+	err = destination.UpdateTag(snapshot, "Status", next)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseReport(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	if destination.GetDBInstanceStatus(snapshot) != "available" {
+		return nil
+	}
+
+	status := "ok"
+
+	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = connect(destination, dbInfo, instance)
+	if err != nil {
+		status = "critical"
+	} else {
+		report := destination.RunReport(snapshot, instance.Report.Queries)
+		key := instance.Report.Prefix + checks.InstanceKey(*instance) + "/" + *snapshot.DBSnapshotIdentifier + ".json"
+		err = destination.UploadReport(instance.Report.Bucket, key, report)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Bucket":       instance.Report.Bucket,
+				"Key":          key,
+			}).WithError(err).Error("Could not upload the report")
+			status = "critical"
+		}
+	}
+
+	err = destination.PostDatadogChecks(snapshot, "rdscheck.report", status, "check")
+	if err != nil {
+		log.WithError(err).Error("Could not update datadog status")
+		return err
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", nextState(instance, Report))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseExport(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	if destination.GetDBInstanceStatus(snapshot) != "available" {
+		return nil
+	}
+
+	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = connect(destination, dbInfo, instance)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range instance.Jobs {
+		status := "ok"
+		key := checks.ExportKey(job, checks.InstanceKey(*instance), *snapshot.DBSnapshotIdentifier)
+		tags := []string{"job:" + job.Name}
+
+		count, err := destination.ExportQuery(job.Query, job.Format, job.Bucket, key)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Job":          job.Name,
+				"Bucket":       job.Bucket,
+				"Key":          key,
+			}).WithError(err).Error("Could not export query")
+			status = "critical"
+		} else {
+			err = destination.PostDatadogMetric(snapshot, "rdscheck.export.rows", float64(count), "check", tags)
+			if err != nil {
+				log.WithError(err).Error("Could not post datadog metric")
+			}
+		}
+
+		err = destination.PostDatadogChecks(snapshot, "rdscheck.export."+job.Name, status, "check")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", nextState(instance, Export))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseSanitize(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	if destination.GetDBInstanceStatus(snapshot) != "available" {
+		return nil
+	}
+
+	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = connect(destination, dbInfo, instance)
+	if err != nil {
+		return sanitizeDone(destination, snapshot, instance, "critical")
+	}
+
+	err = destination.RunScript(instance.Sanitize.Script)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+		}).WithError(err).Error("Could not sanitize the database")
+		return sanitizeDone(destination, snapshot, instance, "critical")
+	}
+
+	sanitized, err := destination.CreateSanitizedSnapshot(snapshot)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+		}).WithError(err).Error("Could not create the sanitized snapshot")
+		return sanitizeDone(destination, snapshot, instance, "critical")
+	}
+
+	err = destination.UpdateTag(snapshot, "SanitizedSnapshot", sanitized)
+	if err != nil {
+		return err
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", "sharing")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseSharing(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	sanitized := destination.GetTagValue(*snapshot.DBSnapshotArn, "SanitizedSnapshot")
+
+	status := destination.GetSnapshotStatus(sanitized)
+	if status == "creating" {
+		return nil
+	}
+
+	if status != "available" {
+		log.WithFields(log.Fields{
+			"Snapshot": sanitized,
+		}).Error("Sanitized snapshot creation failed")
+		return sanitizeDone(destination, snapshot, instance, "critical")
+	}
+
+	if len(instance.Sanitize.Accounts) > 0 {
+		err := destination.ShareSnapshot(sanitized, instance.Sanitize.Accounts)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Snapshot": sanitized,
+			}).WithError(err).Error("Could not share the sanitized snapshot")
+			return sanitizeDone(destination, snapshot, instance, "critical")
+		}
+	}
+
+	return sanitizeDone(destination, snapshot, instance, "ok")
+}
+
+// sanitizeDone reports the result of the sanitization to datadog and moves the snapshot to the next state
+func sanitizeDone(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances, status string) error {
+	err := destination.PostDatadogChecks(snapshot, "rdscheck.sanitize", status, "check")
+	if err != nil {
+		log.WithError(err).Error("Could not update datadog status")
+		return err
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", nextState(instance, Sanitize))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseUpgrade(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	if destination.GetDBInstanceStatus(snapshot) != "available" {
+		return nil
+	}
+
+	err := destination.UpgradeDB(snapshot, instance.UpgradeTo)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"RDS Instance":   *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			"Engine Version": instance.UpgradeTo,
+		}).WithError(err).Error("Could not upgrade the rds instance")
+		return upgradeDone(destination, snapshot, instance, "critical")
+	}
+
+	err = destination.UpdateTag(snapshot, "UpgradeStartTime", utils.GetUnixTimeAsString())
+	if err != nil {
+		return err
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", "upgrading")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func caseUpgrading(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	dbInfo, err := destination.GetDBInstanceInfo(snapshot)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+		}).Info("Could not get RDS instance Info")
+		return err
+	}
+
+	if dbInfo == nil {
+		log.WithFields(log.Fields{
+			"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+		}).Error("The upgraded rds instance doesn't exist anymore")
+		// the next stages would wait forever for the instance, alarm sends it to clean
+		err := destination.PostDatadogChecks(snapshot, "rdscheck.upgrade", "critical", "check")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+		return destination.UpdateTag(snapshot, "Status", Alarm)
+	}
+
+	if *dbInfo.DBInstanceStatus != "available" {
+		return nil
+	}
+
+	if !upgradedTo(*dbInfo.EngineVersion, instance.UpgradeTo) {
+		if dbInfo.PendingModifiedValues != nil && dbInfo.PendingModifiedValues.EngineVersion != nil {
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"RDS Instance":   *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			"Engine Version": *dbInfo.EngineVersion,
+			"Upgrade To":     instance.UpgradeTo,
+		}).Error("Upgrade failed")
+		return upgradeDone(destination, snapshot, instance, "critical")
+	}
+
+	start, err := strconv.ParseInt(destination.GetTagValue(*snapshot.DBSnapshotArn, "UpgradeStartTime"), 10, 64)
+	if err == nil {
+		duration := float64(time.Now().Unix() - start)
+		err = destination.UpdateTag(snapshot, "UpgradeDuration", strconv.FormatFloat(duration, 'f', 0, 64))
+		if err != nil {
+			return err
+		}
+		err = destination.PostDatadogMetric(snapshot, "rdscheck.upgrade.duration", duration, "check", []string{"engine_version:" + instance.UpgradeTo})
+		if err != nil {
+			log.WithError(err).Error("Could not post datadog metric")
+		}
+	}
+
+	_, err = connect(destination, dbInfo, instance)
+	if err != nil {
+		return upgradeDone(destination, snapshot, instance, "critical")
+	}
+
+	for _, query := range instance.Queries {
+		if hasRegex(query) && !destination.CheckRegexAgainstRow(query.Query, query.Regex) {
+			log.WithFields(log.Fields{
+				"RDS Instance":   *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+				"Engine Version": *dbInfo.EngineVersion,
+				"Query":          query.Query,
+				"Regex":          query.Regex,
+			}).Error("Query matched failed after upgrade")
+			return upgradeDone(destination, snapshot, instance, "critical")
+		}
+	}
+
+	return upgradeDone(destination, snapshot, instance, "ok")
+}
+
+// connect opens the connection to the restored database and returns the password it used.
+// With IAM authentication the password is a token generated for this connection.
+func connect(destination checks.DefaultChecks, dbInfo *rds.DBInstance, instance *checks.Instances) (string, error) {
+	password := instance.Password
+	if instance.IAMAuth {
+		token, err := destination.BuildAuthToken(dbInfo)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *dbInfo.DBInstanceIdentifier,
+			}).WithError(err).Error("Could not generate an IAM authentication token")
+			return "", err
+		}
+		password = token
+	}
+
+	err := destination.InitDb(dbInfo, password, instance.Database, sslMode(instance))
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// sslMode returns the ssl mode used to connect to the restored database of an instance
+func sslMode(instance *checks.Instances) string {
+	if instance.SSLMode != "" {
+		return instance.SSLMode
+	}
+	return config.SSLMode
+}
+
+// hasRegex returns true if the result of a query has to match its regex.
+// A query compared with a golden file doesn't need a regex.
+func hasRegex(query checks.Queries) bool {
+	return query.Regex != "" || query.Golden == ""
+}
+
+// upgradedTo returns true if an engine version is the version we upgrade to,
+// or one of its minor versions: 12 matches 12.4 but not 1 or 120.
+func upgradedTo(version, upgradeTo string) bool {
+	return version == upgradeTo || strings.HasPrefix(version, upgradeTo+".")
+}
+
+// upgradeDone reports the result of an upgrade rehearsal to datadog and moves the snapshot to the next state.
+// A failed upgrade doesn't mean the snapshot is broken so it doesn't go through the alarm state.
+func upgradeDone(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances, status string) error {
+	err := destination.PostDatadogChecks(snapshot, "rdscheck.upgrade", status, "check")
+	if err != nil {
+		log.WithError(err).Error("Could not update datadog status")
+		return err
+	}
+
+	err = destination.UpdateTag(snapshot, "Status", nextState(instance, Upgrade))
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -257,7 +1042,19 @@ func caseAlarm(destination checks.DefaultChecks, snapshot *rds.DBSnapshot) error
 	return nil
 }
 
-func caseClean(destination checks.DefaultChecks, snapshot *rds.DBSnapshot) error {
+// caseClean deletes the generated password then the restored instance.
+// Both deletions succeed when they are retried so a failure is retried on the next run.
+func caseClean(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) error {
+	if instance.RandomPassword {
+		err := destination.DeleteRunPassword(snapshot)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": *snapshot.DBInstanceIdentifier + "-" + *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not delete the generated password")
+			return err
+		}
+	}
+
 	err := destination.DeleteDB(snapshot)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -266,6 +1063,8 @@ func caseClean(destination checks.DefaultChecks, snapshot *rds.DBSnapshot) error
 		return err
 	}
 
+	recordRTO(destination, snapshot, "total")
+
 	err = destination.UpdateTag(snapshot, "Status", "tested")
 	if err != nil {
 		return err
@@ -291,3 +1090,29 @@ func caseTested(destination checks.DefaultChecks, snapshot *rds.DBSnapshot) erro
 	}
 	return nil
 }
+
+// recordRTO measures how many seconds elapsed since the restore started for a step,
+// stores it as a tag on the snapshot and sends it to datadog.
+// It returns false if the restore start time is unknown.
+func recordRTO(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, step string) (float64, bool) {
+	start, err := strconv.ParseInt(destination.GetTagValue(*snapshot.DBSnapshotArn, "RestoreStartTime"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	seconds := float64(time.Now().Unix() - start)
+
+	err = destination.UpdateTag(snapshot, rtoTags[step], strconv.FormatFloat(seconds, 'f', 0, 64))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Snapshot": *snapshot.DBSnapshotIdentifier,
+		}).WithError(err).Error("Could not store RTO")
+	}
+
+	err = destination.PostDatadogMetric(snapshot, "rdscheck.rto."+step, seconds, "check", nil)
+	if err != nil {
+		log.WithError(err).Error("Could not post datadog metric")
+	}
+
+	return seconds, true
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/techdroplabs/rdscheck/checks"
+	"github.com/techdroplabs/rdscheck/config"
 )
 
 type mockDefaultChecks struct {
@@ -19,7 +24,9 @@ type mockDefaultChecks struct {
 }
 
 var singleSnapshot = &rds.DBSnapshot{
+	DBInstanceIdentifier: aws.String("test"),
 	DBSnapshotIdentifier: aws.String("test"),
+	DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
 }
 
 var singleInstance = &checks.Instances{
@@ -39,8 +46,31 @@ var singleInstance = &checks.Instances{
 			Regex: "^pg_test$",
 		},
 	},
+	Hooks: []checks.Hooks{
+		checks.Hooks{
+			Command: "/opt/checks/data-quality.py",
+			Timeout: 60,
+		},
+	},
+	Benchmark: checks.Benchmark{
+		Warmup:     1,
+		Iterations: 5,
+		Queries: []checks.BenchmarkQueries{
+			checks.BenchmarkQueries{
+				Query: "SELECT count(*) FROM pg_catalog.pg_tables;",
+				P95:   200,
+			},
+		},
+	},
+	Logs: checks.Logs{
+		Fail: []string{"PANIC", "invalid page in block"},
+		Warn: []string{"WARNING"},
+	},
 }
 
+// benchmarkTag matches the tags where the benchmark latencies are stored
+var benchmarkTag = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "Benchmark") })
+
 var rdsInstance = &rds.DBInstance{
 	DBInstanceArn:    aws.String("arn:aws:rds:us-west-2:123456789012:rds:test"),
 	DBInstanceStatus: aws.String("resetting-master-credentials"),
@@ -57,6 +87,21 @@ func (m *mockDefaultChecks) GetSnapshots(DBInstanceIdentifier string) ([]*rds.DB
 	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
 }
 
+func (m *mockDefaultChecks) GetSanitizedSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error) {
+	args := m.Called(DBInstanceIdentifier)
+	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetOldSnapshots(snapshots []*rds.DBSnapshot, retention int) ([]*rds.DBSnapshot, error) {
+	args := m.Called(snapshots, retention)
+	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) DeleteOldSnapshot(snapshot *rds.DBSnapshot) error {
+	args := m.Called(snapshot)
+	return args.Error(0)
+}
+
 func (m *mockDefaultChecks) CheckTag(arn string, key string, value string) bool {
 	args := m.Called(arn, key, value)
 	return args.Bool(0)
@@ -72,6 +117,11 @@ func (m *mockDefaultChecks) PostDatadogChecks(snapshot *rds.DBSnapshot, metricNa
 	return args.Error(0)
 }
 
+func (m *mockDefaultChecks) PostDatadogCheckMessage(snapshot *rds.DBSnapshot, metricName, status, message, cmdName string, tags []string) error {
+	args := m.Called(snapshot, metricName, status, message, cmdName, tags)
+	return args.Error(0)
+}
+
 func (m *mockDefaultChecks) CreateDatabaseSubnetGroup(snapshot *rds.DBSnapshot, subnetids []string) error {
 	args := m.Called(snapshot, subnetids)
 	return args.Error(0)
@@ -82,8 +132,8 @@ func (m *mockDefaultChecks) UpdateTag(snapshot *rds.DBSnapshot, key, value strin
 	return args.Error(0)
 }
 
-func (m *mockDefaultChecks) CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string) error {
-	args := m.Called(snapshot, instancetype, vpcsecuritygroupids)
+func (m *mockDefaultChecks) CreateDBFromSnapshot(snapshot *rds.DBSnapshot, instancetype string, vpcsecuritygroupids []string, iamauth bool) error {
+	args := m.Called(snapshot, instancetype, vpcsecuritygroupids, iamauth)
 	return args.Error(0)
 }
 
@@ -102,6 +152,51 @@ func (m *mockDefaultChecks) ChangeDBpassword(snapshot *rds.DBSnapshot, DBArn, pa
 	return args.Error(0)
 }
 
+func (m *mockDefaultChecks) ScanDBLogs(snapshot *rds.DBSnapshot, patterns []string) ([]checks.LogMatch, error) {
+	args := m.Called(snapshot, patterns)
+	return args.Get(0).([]checks.LogMatch), args.Error(1)
+}
+
+func (m *mockDefaultChecks) RunReport(snapshot *rds.DBSnapshot, queries []checks.ReportQueries) checks.Report {
+	args := m.Called(snapshot, queries)
+	return args.Get(0).(checks.Report)
+}
+
+func (m *mockDefaultChecks) UploadReport(bucket, key string, report checks.Report) error {
+	args := m.Called(bucket, key, report)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) ExportQuery(query, format, bucket, key string) (int64, error) {
+	args := m.Called(query, format, bucket, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockDefaultChecks) RunScript(script string) error {
+	args := m.Called(script)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) CreateSanitizedSnapshot(snapshot *rds.DBSnapshot) (string, error) {
+	args := m.Called(snapshot)
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetSnapshotStatus(DBSnapshotIdentifier string) string {
+	args := m.Called(DBSnapshotIdentifier)
+	return args.Get(0).(string)
+}
+
+func (m *mockDefaultChecks) ShareSnapshot(DBSnapshotIdentifier string, accounts []string) error {
+	args := m.Called(DBSnapshotIdentifier, accounts)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) UpgradeDB(snapshot *rds.DBSnapshot, engineVersion string) error {
+	args := m.Called(snapshot, engineVersion)
+	return args.Error(0)
+}
+
 func (m *mockDefaultChecks) CheckRegexAgainstRow(query, regex string) bool {
 	args := m.Called(query, regex)
 	return args.Bool(0)
@@ -122,11 +217,81 @@ func (m *mockDefaultChecks) DeleteDatabaseSubnetGroup(snapshot *rds.DBSnapshot)
 	return args.Error(0)
 }
 
-func (m *mockDefaultChecks) InitDb(db *rds.DBInstance, password, dbname string) error {
-	args := m.Called(db, password, dbname)
+func (m *mockDefaultChecks) InitDb(db *rds.DBInstance, password, dbname, sslmode string) error {
+	args := m.Called(db, password, dbname, sslmode)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) RunHook(db *rds.DBInstance, password, dbname, sslmode string, hook checks.Hooks) (checks.HookResult, error) {
+	args := m.Called(db, password, dbname, sslmode, hook)
+	return args.Get(0).(checks.HookResult), args.Error(1)
+}
+
+func (m *mockDefaultChecks) RunBenchmark(query string, warmup, iterations int) (checks.BenchmarkResult, error) {
+	args := m.Called(query, warmup, iterations)
+	return args.Get(0).(checks.BenchmarkResult), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetBenchmarkBaseline(snapshot *rds.DBSnapshot, name string) (checks.BenchmarkResult, bool) {
+	args := m.Called(snapshot, name)
+	return args.Get(0).(checks.BenchmarkResult), args.Bool(1)
+}
+
+func (m *mockDefaultChecks) PostDatadogMetric(snapshot *rds.DBSnapshot, metricName string, value float64, cmdName string, tags []string) error {
+	args := m.Called(snapshot, metricName, value, cmdName, tags)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) GetPointInTimeRestore(DBInstanceIdentifier string) (*rds.DBSnapshot, error) {
+	args := m.Called(DBInstanceIdentifier)
+	return args.Get(0).(*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) PointInTimeRestoreDue(DBInstanceIdentifier string, interval int) bool {
+	args := m.Called(DBInstanceIdentifier, interval)
+	return args.Bool(0)
+}
+
+func (m *mockDefaultChecks) CompareGolden(query checks.Queries, bucket, key string) ([]string, error) {
+	args := m.Called(query, bucket, key)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockDefaultChecks) AcceptGolden(bucket, key string) error {
+	args := m.Called(bucket, key)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) GetPassword(ref string) (string, error) {
+	args := m.Called(ref)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDefaultChecks) CreateRunPassword(snapshot *rds.DBSnapshot) (string, error) {
+	args := m.Called(snapshot)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetRunPassword(snapshot *rds.DBSnapshot) (string, error) {
+	args := m.Called(snapshot)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDefaultChecks) BuildAuthToken(db *rds.DBInstance) (string, error) {
+	args := m.Called(db)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDefaultChecks) DeleteRunPassword(snapshot *rds.DBSnapshot) error {
+	args := m.Called(snapshot)
 	return args.Error(0)
 }
 
+func (m *mockDefaultChecks) CreateDBFromPointInTime(DBInstanceIdentifier, instancetype string, vpcsecuritygroupids, subnetids []string, iamauth bool) (time.Time, error) {
+	args := m.Called(DBInstanceIdentifier, instancetype, vpcsecuritygroupids, subnetids, iamauth)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
 func (m *mockDefaultChecks) SetSessions(region string) {
 	m.Called(region)
 }
@@ -170,6 +335,93 @@ func TestGetDoc(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestGetDocPasswordRef(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	doc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "rdscheck",
+				PasswordRef: "ssm:/rdscheck/password",
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetYamlFileFromS3", mock.Anything, mock.Anything).Return(ioutil.NopCloser(nil), nil)
+	c.On("UnmarshalYamlFile", mock.Anything).Return(doc, nil)
+	c.On("GetPassword", "ssm:/rdscheck/password").Return("fromssm", nil)
+
+	value, err := getDoc(c)
+	assert.Nil(t, err)
+	assert.Equal(t, value.Instances[0].Password, "fromssm")
+	c.AssertExpectations(t)
+}
+
+func TestValidatePointInTimeStart(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	doc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name: "test",
+				Type: "db.t2.micro",
+				PITR: checks.PITR{
+					Interval:         24,
+					SubnetIds:        []string{"subnet-source"},
+					SecurityGroupIds: []string{"sg-source"},
+				},
+			},
+			checks.Instances{
+				Name: "nopitr",
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetPointInTimeRestore", "test").Return((*rds.DBSnapshot)(nil), nil)
+	c.On("PointInTimeRestoreDue", "test", 24).Return(true)
+	c.On("CreateDBFromPointInTime", "test", "db.t2.micro", []string{"sg-source"}, []string{"subnet-source"}, false).Return(time.Now(), nil)
+
+	err := validatePointInTime(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestValidatePointInTimeInProgress(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	doc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name: "test",
+				PITR: checks.PITR{
+					Interval: 24,
+				},
+			},
+		},
+	}
+
+	pitr := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("test"),
+		DBSnapshotIdentifier: aws.String("pitr"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:db:test-pitr"),
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetPointInTimeRestore", "test").Return(pitr, nil)
+	c.On("GetTagValue", *pitr.DBSnapshotArn, "Status").Return("clean")
+	c.On("GetTagValue", *pitr.DBSnapshotArn, "RestoreStartTime").Return("")
+	c.On("DeleteDB", pitr).Return(nil)
+	c.On("UpdateTag", pitr, "Status", "tested").Return(nil)
+
+	err := validatePointInTime(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
 func TestCaseReady(t *testing.T) {
 	c := &mockDefaultChecks{}
 
@@ -186,7 +438,7 @@ func TestCaseReady(t *testing.T) {
 func TestCaseRestore(t *testing.T) {
 	c := &mockDefaultChecks{}
 
-	c.On("CreateDBFromSnapshot", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CreateDBFromSnapshot", mock.Anything, mock.Anything, mock.Anything, false).Return(nil)
 	c.On("UpdateTag", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	err := caseRestore(c, singleSnapshot, singleInstance)
@@ -195,9 +447,51 @@ func TestCaseRestore(t *testing.T) {
 	c.AssertExpectations(t)
 }
 
+func TestCaseRestoreIAMAuth(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.IAMAuth = true
+
+	c.On("CreateDBFromSnapshot", mock.Anything, mock.Anything, mock.Anything, true).Return(nil)
+	c.On("UpdateTag", mock.Anything, "RestoreStartTime", mock.Anything).Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "verify").Return(nil)
+
+	err := caseRestore(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestConnectIAMAuth(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.IAMAuth = true
+
+	c.On("BuildAuthToken", rdsInstance).Return("token", nil)
+	c.On("InitDb", rdsInstance, "token", instance.Database, "verify-full").Return(nil)
+
+	password, err := connect(c, rdsInstance, &instance)
+
+	assert.Nil(t, err)
+	assert.Equal(t, password, "token")
+	c.AssertExpectations(t)
+}
+
+func TestSSLMode(t *testing.T) {
+	instance := *singleInstance
+
+	assert.Equal(t, sslMode(&instance), "verify-full")
+
+	instance.SSLMode = "require"
+	assert.Equal(t, sslMode(&instance), "require")
+}
+
 func TestCaseModify(t *testing.T) {
 	c := &mockDefaultChecks{}
 
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
 	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
 	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
 	c.On("ChangeDBpassword", mock.Anything, mock.Anything, mock.Anything).Return(nil)
@@ -209,21 +503,575 @@ func TestCaseModify(t *testing.T) {
 	c.AssertExpectations(t)
 }
 
+func TestCaseModifyRandomPassword(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.RandomPassword = true
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("CreateRunPassword", singleSnapshot).Return("generated", nil)
+	c.On("ChangeDBpassword", mock.Anything, mock.Anything, "generated").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "verify").Return(nil)
+
+	err := caseModify(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestProcessRandomPassword(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.RandomPassword = true
+	instance.Report = checks.Reports{
+		Bucket: "reports",
+	}
+
+	c.On("GetRunPassword", singleSnapshot).Return("generated", nil)
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, "generated", mock.Anything, mock.Anything).Return(nil)
+	c.On("RunReport", mock.Anything, mock.Anything).Return(checks.Report{})
+	c.On("UploadReport", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.report", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", mock.Anything).Return(nil)
+
+	err := process(c, singleSnapshot, &instance, Report)
+
+	assert.Nil(t, err)
+	assert.Equal(t, instance.Password, singleInstance.Password)
+	c.AssertExpectations(t)
+}
+
 func TestCaseVerify(t *testing.T) {
 	c := &mockDefaultChecks{}
 
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
 	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
 	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
-	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 20}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("ScanDBLogs", mock.Anything, mock.Anything).Return([]checks.LogMatch{}, nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.logs", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, singleInstance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyPointInTimeSkipsStages(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Report.Bucket = "rdscheck-reports"
+	instance.Sanitize.Script = "sanitize.sql"
+
+	pitr := &rds.DBSnapshot{
+		DBInstanceIdentifier: aws.String("test"),
+		DBSnapshotIdentifier: aws.String("pitr"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:db:test-pitr"),
+	}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 20}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("ScanDBLogs", mock.Anything, mock.Anything).Return([]checks.LogMatch{}, nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.logs", "ok", "check").Return(nil)
+	c.On("UpdateTag", pitr, "Status", "clean").Return(nil)
+
+	err := caseVerify(c, pitr, &instance)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "UpdateTag", mock.Anything, "Status", "report")
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyHookFailed(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 1, Stderr: "missing rows"}, errors.New("exit status 1"))
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "critical", "exit code 1\nstderr:\nmissing rows", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, singleInstance)
+
+	assert.NotNil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyBenchmarkThreshold(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 150, P95: 450}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, singleInstance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyBenchmarkBaseline(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Benchmark.Baseline = 1.5
+	instance.Benchmark.Queries = []checks.BenchmarkQueries{
+		checks.BenchmarkQueries{
+			Name:  "tables",
+			Query: "SELECT count(*) FROM pg_catalog.pg_tables;",
+		},
+	}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 40}, nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, []string{"query:tables"}).Return(nil)
+	c.On("UpdateTag", mock.Anything, "BenchmarkP50:tables", "10.00").Return(nil)
+	c.On("UpdateTag", mock.Anything, "BenchmarkP95:tables", "40.00").Return(nil)
+	c.On("GetBenchmarkBaseline", singleSnapshot, "tables").Return(checks.BenchmarkResult{P50: 10, P95: 20}, true)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyRTOExceeded(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.RTO = 60
+
+	start := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return(start)
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 20}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("ScanDBLogs", mock.Anything, mock.Anything).Return([]checks.LogMatch{}, nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.rto", "critical", "check").Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.logs", "ok", "check").Return(nil)
 	c.On("UpdateTag", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
+	err := caseVerify(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyGoldenMismatch(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Golden = checks.Golden{
+		Bucket: "golden",
+		Prefix: "golden/",
+	}
+	instance.Queries = []checks.Queries{
+		checks.Queries{
+			Query:  "SELECT id FROM users",
+			Golden: "users.csv",
+		},
+	}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CompareGolden", instance.Queries[0], "golden", "golden/test/users.csv").Return([]string{"row 1: unexpected [1]"}, nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "CheckRegexAgainstRow", mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCaseVerifyLogsScanFailed(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 20}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("ScanDBLogs", mock.Anything, mock.Anything).Return([]checks.LogMatch(nil), errors.New("DBLogFileNotFoundFault"))
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.logs", "critical", "DBLogFileNotFoundFault", "check", []string(nil)).Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
 	err := caseVerify(c, singleSnapshot, singleInstance)
 
 	assert.Nil(t, err)
 	c.AssertExpectations(t)
 }
 
+func TestCaseVerifyLogsFailed(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	matches := []checks.LogMatch{
+		checks.LogMatch{
+			File:    "error/postgresql.log.2019-11-20-00",
+			Line:    "WARNING:  out of shared memory",
+			Pattern: "WARNING",
+		},
+		checks.LogMatch{
+			File:    "error/postgresql.log.2019-11-20-00",
+			Line:    "ERROR:  invalid page in block 42 of relation base/16384/16385",
+			Pattern: "invalid page in block",
+		},
+	}
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("RunHook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(checks.HookResult{ExitCode: 0}, nil)
+	c.On("PostDatadogCheckMessage", mock.Anything, "rdscheck.hook", "ok", "exit code 0", "check", []string{"hook:data-quality.py"}).Return(nil)
+	c.On("RunBenchmark", mock.Anything, mock.Anything, mock.Anything).Return(checks.BenchmarkResult{P50: 10, P95: 20}, nil)
+	c.On("UpdateTag", mock.Anything, benchmarkTag, mock.Anything).Return(nil)
+	c.On("PostDatadogMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("ScanDBLogs", mock.Anything, []string{"PANIC", "invalid page in block", "WARNING"}).Return(matches, nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.logs", "critical", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseVerify(c, singleSnapshot, singleInstance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestNextState(t *testing.T) {
+	instance := *singleInstance
+
+	assert.Equal(t, nextState(&instance, Verify), Clean)
+
+	instance.UpgradeTo = "12.4"
+	assert.Equal(t, nextState(&instance, Verify), Upgrade)
+	assert.Equal(t, nextState(&instance, Upgrade), Clean)
+
+	instance.Report.Bucket = "rdscheck-reports"
+	assert.Equal(t, nextState(&instance, Verify), Report)
+	assert.Equal(t, nextState(&instance, Report), Upgrade)
+
+	instance.Jobs = []checks.Jobs{checks.Jobs{Name: "orders"}}
+	assert.Equal(t, nextState(&instance, Report), Export)
+	assert.Equal(t, nextState(&instance, Export), Upgrade)
+
+	instance.Sanitize.Script = "UPDATE users SET email = md5(email);"
+	assert.Equal(t, nextState(&instance, Export), Upgrade)
+	assert.Equal(t, nextState(&instance, Upgrade), Sanitize)
+	assert.Equal(t, nextState(&instance, Sanitize), Clean)
+}
+
+func TestCaseSanitize(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Sanitize = checks.Sanitize{
+		Script:   "UPDATE users SET email = md5(email);",
+		Accounts: []string{"123456789012"},
+	}
+
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("RunScript", "UPDATE users SET email = md5(email);").Return(nil)
+	c.On("CreateSanitizedSnapshot", singleSnapshot).Return("test-sanitized", nil)
+	c.On("UpdateTag", mock.Anything, "SanitizedSnapshot", "test-sanitized").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "sharing").Return(nil)
+
+	err := caseSanitize(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseSharing(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Sanitize = checks.Sanitize{
+		Script:   "UPDATE users SET email = md5(email);",
+		Accounts: []string{"123456789012"},
+	}
+
+	c.On("GetTagValue", mock.Anything, "SanitizedSnapshot").Return("test-sanitized")
+	c.On("GetSnapshotStatus", "test-sanitized").Return("available")
+	c.On("ShareSnapshot", "test-sanitized", []string{"123456789012"}).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.sanitize", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseSharing(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseSharingCreating(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("GetTagValue", mock.Anything, "SanitizedSnapshot").Return("test-sanitized")
+	c.On("GetSnapshotStatus", "test-sanitized").Return("creating")
+
+	err := caseSharing(c, singleSnapshot, singleInstance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseExport(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Jobs = []checks.Jobs{
+		checks.Jobs{
+			Name:   "orders",
+			Query:  "SELECT * FROM orders;",
+			Format: "jsonl",
+			Bucket: "rdscheck-exports",
+			Prefix: "exports/",
+		},
+		checks.Jobs{
+			Name:   "users",
+			Query:  "SELECT * FROM users;",
+			Bucket: "rdscheck-exports",
+		},
+	}
+
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("ExportQuery", "SELECT * FROM orders;", "jsonl", "rdscheck-exports", "exports/test/test/orders.jsonl").Return(int64(42), nil)
+	c.On("ExportQuery", "SELECT * FROM users;", "", "rdscheck-exports", "test/test/users.csv").Return(int64(0), errors.New("AccessDenied"))
+	c.On("PostDatadogMetric", mock.Anything, "rdscheck.export.rows", float64(42), "check", []string{"job:orders"}).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.export.orders", "ok", "check").Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.export.users", "critical", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseExport(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseReport(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.Report = checks.Reports{
+		Bucket: "rdscheck-reports",
+		Prefix: "reports/",
+	}
+
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("RunReport", singleSnapshot, mock.Anything).Return(checks.Report{})
+	c.On("UploadReport", "rdscheck-reports", "reports/test/test.json", mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.report", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseReport(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseReportMultiDestination(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.MultiDestination = true
+	instance.Report = checks.Reports{
+		Bucket: "rdscheck-reports",
+		Prefix: "reports/",
+	}
+
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("GetDBInstanceInfo", mock.Anything).Return(rdsInstance, nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("RunReport", singleSnapshot, mock.Anything).Return(checks.Report{})
+	c.On("UploadReport", "rdscheck-reports", "reports/test/us-west-2/test.json", mock.Anything).Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.report", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseReport(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseUpgrade(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.UpgradeTo = "12.4"
+
+	c.On("GetDBInstanceStatus", mock.Anything).Return("available")
+	c.On("UpgradeDB", mock.Anything, "12.4").Return(nil)
+	c.On("UpdateTag", mock.Anything, "UpgradeStartTime", mock.Anything).Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "upgrading").Return(nil)
+
+	err := caseUpgrade(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseUpgrading(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.UpgradeTo = "12"
+
+	upgraded := *rdsInstance
+	upgraded.DBInstanceStatus = aws.String("available")
+	upgraded.EngineVersion = aws.String("12.4")
+
+	start := strconv.FormatInt(time.Now().Add(-20*time.Minute).Unix(), 10)
+
+	c.On("GetDBInstanceInfo", mock.Anything).Return(&upgraded, nil)
+	c.On("GetTagValue", mock.Anything, "UpgradeStartTime").Return(start)
+	c.On("UpdateTag", mock.Anything, "UpgradeDuration", mock.Anything).Return(nil)
+	c.On("PostDatadogMetric", mock.Anything, "rdscheck.upgrade.duration", mock.Anything, "check", mock.Anything).Return(nil)
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.upgrade", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseUpgrading(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseUpgradingBeforeSanitize(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.UpgradeTo = "12"
+	instance.Sanitize = checks.Sanitize{
+		Script: "UPDATE users SET email = md5(email);",
+	}
+
+	upgraded := *rdsInstance
+	upgraded.DBInstanceStatus = aws.String("available")
+	upgraded.EngineVersion = aws.String("12.4")
+
+	assert.Equal(t, Upgrade, nextState(&instance, Verify))
+
+	c.On("GetDBInstanceInfo", mock.Anything).Return(&upgraded, nil)
+	c.On("GetTagValue", mock.Anything, "UpgradeStartTime").Return("")
+	c.On("InitDb", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CheckRegexAgainstRow", mock.Anything, mock.Anything).Return(true)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.upgrade", "ok", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "sanitize").Return(nil)
+
+	err := caseUpgrading(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "RunScript", mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCaseUpgradingFailed(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.UpgradeTo = "12.4"
+
+	upgraded := *rdsInstance
+	upgraded.DBInstanceStatus = aws.String("available")
+	upgraded.EngineVersion = aws.String("11.8")
+	upgraded.PendingModifiedValues = &rds.PendingModifiedValues{}
+
+	c.On("GetDBInstanceInfo", mock.Anything).Return(&upgraded, nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.upgrade", "critical", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "clean").Return(nil)
+
+	err := caseUpgrading(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseUpgradingInstanceGone(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.UpgradeTo = "12.4"
+	instance.Sanitize.Script = "UPDATE users SET email = md5(email);"
+
+	c.On("GetDBInstanceInfo", mock.Anything).Return((*rds.DBInstance)(nil), nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.upgrade", "critical", "check").Return(nil)
+	c.On("UpdateTag", mock.Anything, "Status", "alarm").Return(nil)
+
+	err := caseUpgrading(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestUpgradedTo(t *testing.T) {
+	assert.True(t, upgradedTo("12.4", "12.4"))
+	assert.True(t, upgradedTo("12.4", "12"))
+	assert.False(t, upgradedTo("10.14", "1"))
+	assert.False(t, upgradedTo("11.8", "1"))
+	assert.False(t, upgradedTo("12.14", "12.1"))
+}
+
 func TestCaseAlarm(t *testing.T) {
 	c := &mockDefaultChecks{}
 
@@ -239,12 +1087,63 @@ func TestCaseAlarm(t *testing.T) {
 func TestCaseClean(t *testing.T) {
 	c := &mockDefaultChecks{}
 
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
 	c.On("DeleteDB", mock.Anything).Return(nil)
 	c.On("UpdateTag", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
-	err := caseClean(c, singleSnapshot)
+	err := caseClean(c, singleSnapshot, singleInstance)
 
 	assert.Nil(t, err)
+	c.AssertNotCalled(t, "DeleteRunPassword", mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCaseCleanRandomPassword(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.RandomPassword = true
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return("")
+	c.On("DeleteDB", mock.Anything).Return(nil)
+	c.On("DeleteRunPassword", singleSnapshot).Return(nil)
+	c.On("UpdateTag", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	err := caseClean(c, singleSnapshot, &instance)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+func TestCaseCleanRandomPasswordFailed(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := *singleInstance
+	instance.RandomPassword = true
+
+	c.On("DeleteRunPassword", singleSnapshot).Return(errors.New("AccessDenied"))
+
+	err := caseClean(c, singleSnapshot, &instance)
+
+	assert.NotNil(t, err)
+	c.AssertNotCalled(t, "DeleteDB", mock.Anything)
+	c.AssertNotCalled(t, "UpdateTag", mock.Anything, mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestRecordRTO(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	start := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	c.On("GetTagValue", mock.Anything, "RestoreStartTime").Return(start)
+	c.On("UpdateTag", mock.Anything, "RTOAvailable", mock.Anything).Return(nil)
+	c.On("PostDatadogMetric", mock.Anything, "rdscheck.rto.available", mock.Anything, "check", mock.Anything).Return(nil)
+
+	value, ok := recordRTO(c, singleSnapshot, "available")
+
+	assert.True(t, ok)
+	assert.InDelta(t, value, 600, 5)
 	c.AssertExpectations(t)
 }
 
@@ -260,3 +1159,115 @@ func TestCaseTested(t *testing.T) {
 	assert.Nil(t, err)
 	c.AssertExpectations(t)
 }
+
+func TestValidateSkipChecks(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	doc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "test",
+				Destination: "eu-west-1",
+				SkipChecks:  true,
+			},
+		},
+	}
+
+	err := validate(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "SetSessions", mock.Anything)
+	c.AssertNotCalled(t, "GetSnapshots", mock.Anything)
+}
+
+func TestProcessDryRunSkipsDatabaseStates(t *testing.T) {
+	config.DryRun = true
+	defer func() { config.DryRun = false }()
+
+	c := &mockDefaultChecks{}
+
+	instance := checks.Instances{
+		Name:           "test",
+		RandomPassword: true,
+	}
+
+	err := process(c, singleSnapshot, &instance, Verify)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "GetRunPassword", mock.Anything)
+	c.AssertNotCalled(t, "GetDBInstanceInfo", mock.Anything)
+	c.AssertNotCalled(t, "UpdateTag", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessDryRunSkipsModify(t *testing.T) {
+	config.DryRun = true
+	defer func() { config.DryRun = false }()
+
+	c := &mockDefaultChecks{}
+
+	instance := checks.Instances{
+		Name:           "test",
+		RandomPassword: true,
+	}
+
+	err := process(c, singleSnapshot, &instance, Modify)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "GetDBInstanceStatus", mock.Anything)
+	c.AssertNotCalled(t, "CreateRunPassword", mock.Anything)
+	c.AssertNotCalled(t, "ChangeDBpassword", mock.Anything, mock.Anything, mock.Anything)
+	c.AssertNotCalled(t, "UpdateTag", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCleanSanitized(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := checks.Instances{
+		Name:      "test",
+		Retention: 7,
+		Sanitize: checks.Sanitize{
+			Script:    "UPDATE users SET email = md5(email);",
+			Accounts:  []string{"123456789012"},
+			Retention: 30,
+		},
+	}
+
+	old := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2020-05-01-03-00-sanitized"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -60)),
+	}
+	recent := &rds.DBSnapshot{
+		DBSnapshotIdentifier: aws.String("rds:test-2020-06-29-03-00-sanitized"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -1)),
+	}
+
+	c.On("GetSanitizedSnapshots", "test").Return([]*rds.DBSnapshot{old, recent}, nil)
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{old, recent}, 30).Return([]*rds.DBSnapshot{old}, nil)
+	c.On("DeleteOldSnapshot", old).Return(nil)
+
+	err := cleanSanitized(c, &instance)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "DeleteOldSnapshot", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCleanSanitizedWithoutRetention(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	instance := checks.Instances{
+		Name: "test",
+		GFS: checks.GFS{
+			Daily: 7,
+		},
+		Sanitize: checks.Sanitize{
+			Script: "UPDATE users SET email = md5(email);",
+		},
+	}
+
+	err := cleanSanitized(c, &instance)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "GetSanitizedSnapshots", mock.Anything)
+	c.AssertNotCalled(t, "DeleteOldSnapshot", mock.Anything)
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/service/rds"
 	log "github.com/sirupsen/logrus"
 	"github.com/techdroplabs/rdscheck/checks"
 	"github.com/techdroplabs/rdscheck/config"
 )
 
 func main() {
+	log.AddHook(&checks.RedactHook{})
 	lambda.Start(run)
 }
 
 func run() {
+	checks.ResetPlan()
+
 	source := checks.New()
 	destination := checks.New()
+	vault := checks.New()
 
 	doc, err := getDoc(source)
 	if err != nil {
@@ -23,17 +29,25 @@ func run() {
 		os.Exit(1)
 	}
 
-	err = copy(source, destination, doc)
+	err = copy(source, destination, vault, doc)
 	if err != nil {
 		log.WithError(err).Error("copy returned:")
 		os.Exit(1)
 	}
 
-	err = clean(destination, doc)
+	err = clean(vault, doc)
 	if err != nil {
 		log.WithError(err).Error("clean returned:")
 		os.Exit(1)
 	}
+
+	err = checkEncryption(vault, doc)
+	if err != nil {
+		log.WithError(err).Error("checkEncryption returned:")
+		os.Exit(1)
+	}
+
+	checks.LogPlan()
 }
 
 func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
@@ -56,70 +70,232 @@ func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
 	return doc, nil
 }
 
-func copy(source checks.DefaultChecks, destination checks.DefaultChecks, doc checks.Doc) error {
-	source.SetSessions(config.AWSRegionSource)
-
+func copy(source checks.DefaultChecks, destination checks.DefaultChecks, vault checks.DefaultChecks, doc checks.Doc) error {
 	for _, instance := range doc.Instances {
-		destination.SetSessions(instance.Destination)
+		checks.SetSourceSessions(source, instance)
+		checks.SetDestinationSessions(destination, instance)
+		if instance.Vault.RoleArn != "" {
+			checks.SetVaultSessions(vault, instance)
+		}
 
 		snapshots, err := source.GetSnapshots(instance.Name)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"RDS Instance": instance.Name,
-				"AWS Region":   config.AWSRegionSource,
+				"AWS Region":   checks.SourceRegion(instance),
 			}).WithError(err).Error("Could not get snapshots")
 			return err
 		}
 
-		for _, snapshot := range snapshots {
-			if *snapshot.SnapshotType == "automated" {
-				err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "ok", "copy")
+		sources, err := sourcesToCopy(source, snapshots, &instance)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+			}).WithError(err).Error("Could not apply the retention to the source snapshots")
+			return err
+		}
+
+		for _, snapshot := range sources {
+			err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "ok", "copy")
+			if err != nil {
+				log.WithError(err).Error("Could not update datadog status")
+				return err
+			}
+
+			if instance.Encrypt {
+				ok, err := checkSourceEncryption(destination, snapshot, &instance)
 				if err != nil {
-					log.WithError(err).Error("Could not update datadog status")
 					return err
 				}
-
-				var preSignedUrl string
-				cleanArn := destination.CleanArn(snapshot)
-
-				if *snapshot.Encrypted {
-					preSignedUrl, err = source.PreSignUrl(instance.Destination, *snapshot.DBSnapshotArn, instance.KmsID, cleanArn)
-					if err != nil {
-						log.WithFields(log.Fields{
-							"snapshot": *snapshot.DBSnapshotIdentifier,
-						}).WithError(err).Error("Could not presigned the url")
-
-						err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "critical", "copy")
-						if err != nil {
-							log.WithError(err).Error("Could not update datadog status")
-							return err
-						}
-						return err
-					}
+				if !ok {
+					continue
 				}
+			}
 
-				err = destination.CopySnapshots(snapshot, instance.Destination, instance.KmsID, preSignedUrl, cleanArn)
+			cleanArn := destination.CleanArn(snapshot)
+			if instance.Vault.RoleArn != "" {
+				err = copyToVault(source, destination, vault, snapshot, &instance, cleanArn)
 				if err != nil {
-					log.WithFields(log.Fields{
-						"Snapshot": *snapshot.DBSnapshotIdentifier,
-					}).WithError(err).Error("Could not copy snapshot")
-
-					err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "critical", "copy")
-					if err != nil {
-						log.WithError(err).Error("Could not update datadog status")
-						return err
-					}
 					return err
 				}
+				continue
+			}
+
+			err = copySnapshot(source, destination, snapshot, &instance, cleanArn)
+			if err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+// selected tells if a snapshot of the source instance has to be copied.
+// The snapshots of the snapshot command are always copied, like the automated ones.
+func selected(source checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) bool {
+	switch *snapshot.SnapshotType {
+	case "automated":
+		return checks.CopiesType(*instance, "automated")
+	case "manual":
+		if checks.IsScheduled(snapshot) {
+			return source.CheckTag(*snapshot.DBSnapshotArn, "ScheduledBy", "rdscheck")
+		}
+		return checks.CopiesType(*instance, "manual") &&
+			!source.CheckTag(*snapshot.DBSnapshotArn, "CreatedBy", "rdscheck") &&
+			source.MatchManual(snapshot, instance.Manual)
+	}
+	return false
+}
+
+// sourcesToCopy returns the selected snapshots of the source instance whose copies
+// would be kept by the retention. The others would be deleted by clean and copied
+// again on the next run.
+func sourcesToCopy(source checks.DefaultChecks, snapshots []*rds.DBSnapshot, instance *checks.Instances) ([]*rds.DBSnapshot, error) {
+	var sources, gfs []*rds.DBSnapshot
+	for _, snapshot := range snapshots {
+		if !selected(source, snapshot, instance) || expired(snapshot, instance) {
+			continue
+		}
+		sources = append(sources, snapshot)
+		if instance.GFS.Enabled() && !manualRetention(snapshot, instance) {
+			gfs = append(gfs, snapshot)
+		}
+	}
+	if len(gfs) == 0 {
+		return sources, nil
+	}
+
+	pruned, err := source.GetGFSOldSnapshots(gfs, instance.GFS)
+	if err != nil {
+		return nil, err
+	}
+	old := map[*rds.DBSnapshot]bool{}
+	for _, snapshot := range pruned {
+		old[snapshot] = true
+	}
+	var kept []*rds.DBSnapshot
+	for _, snapshot := range sources {
+		if !old[snapshot] {
+			kept = append(kept, snapshot)
+		}
+	}
+	return kept, nil
+}
+
+// manualRetention tells if the copy of a snapshot follows the manual retention
+func manualRetention(snapshot *rds.DBSnapshot, instance *checks.Instances) bool {
+	return *snapshot.SnapshotType == "manual" && !checks.IsScheduled(snapshot) && instance.Manual.Retention > 0
+}
+
+// expired tells if a source snapshot is older than the retention in days of its copies
+func expired(snapshot *rds.DBSnapshot, instance *checks.Instances) bool {
+	days := instance.Retention
+	if manualRetention(snapshot, instance) {
+		days = instance.Manual.Retention
+	} else if instance.GFS.Enabled() {
+		return false
+	}
+	if days == 0 {
+		return false
+	}
+	return !snapshot.SnapshotCreateTime.After(time.Now().AddDate(0, 0, -days))
+}
+
+// copySnapshot copies a snapshot from the source region to targetID in the destination region
+func copySnapshot(source, destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances, targetID string) error {
+	var preSignedUrl string
+	var err error
+
+	if *snapshot.Encrypted {
+		preSignedUrl, err = source.PreSignUrl(instance.Destination, *snapshot.DBSnapshotArn, instance.KmsID, targetID)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"snapshot": *snapshot.DBSnapshotIdentifier,
+			}).WithError(err).Error("Could not presigned the url")
+
+			err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "critical", "copy")
+			if err != nil {
+				log.WithError(err).Error("Could not update datadog status")
+				return err
+			}
+			return err
+		}
+	}
+
+	err = destination.CopySnapshots(snapshot, instance.Destination, instance.KmsID, preSignedUrl, targetID, instance.Encrypt)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Snapshot": *snapshot.DBSnapshotIdentifier,
+		}).WithError(err).Error("Could not copy snapshot")
+
+		err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "critical", "copy")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+		return err
+	}
+	return nil
+}
+
+// copyToVault moves a snapshot into the vault account, one step per run:
+// the snapshot is copied to a staging snapshot in the destination region,
+// shared with the vault account, copied there with the vault kms key,
+// then the staging snapshot is unshared and deleted
+func copyToVault(source, destination, vault checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances, cleanArn string) error {
+	stagingID := checks.VaultStagingIdentifier(cleanArn)
+	accounts := []string{checks.VaultAccount(instance.Vault.RoleArn)}
+
+	vaultStatus := vault.GetSnapshotStatus(cleanArn)
+	staging, err := destination.GetSnapshot(stagingID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Snapshot": stagingID,
+		}).WithError(err).Error("Could not get the staging snapshot")
+		return err
+	}
+
+	switch {
+	case vaultStatus == "available":
+		if staging == nil {
+			return nil
+		}
+		err = destination.UnshareSnapshot(stagingID, accounts)
+		if err == nil {
+			err = destination.DeleteOldSnapshot(staging)
+		}
+	case vaultStatus != "":
+		return nil
+	case staging == nil:
+		return copySnapshot(source, destination, snapshot, instance, stagingID)
+	case *staging.Status != "available":
+		return nil
+	default:
+		err = destination.ShareSnapshot(stagingID, accounts)
+		if err == nil {
+			err = vault.CopyVaultSnapshot(snapshot, staging, instance.Vault.KmsID, cleanArn)
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Snapshot": *snapshot.DBSnapshotIdentifier,
+			"Staging":  stagingID,
+		}).WithError(err).Error("Could not copy snapshot to the vault")
+
+		err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "critical", "copy")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+		return err
+	}
+	return nil
+}
+
 func clean(destination checks.DefaultChecks, doc checks.Doc) error {
 	for _, instance := range doc.Instances {
-		destination.SetSessions(instance.Destination)
+		checks.SetVaultSessions(destination, instance)
 
 		snapshots, err := destination.GetSnapshots(instance.Name)
 		if err != nil {
@@ -130,15 +306,40 @@ func clean(destination checks.DefaultChecks, doc checks.Doc) error {
 			return err
 		}
 
-		oldSnapshots, err := destination.GetOldSnapshots(snapshots, instance.Retention)
+		oldSnapshots, err := getOldSnapshots(destination, snapshots, &instance)
 		if err != nil {
 			log.WithError(err).Error("Could not get old snapshots")
 			return err
 		}
 
+		if len(oldSnapshots) == 0 {
+			continue
+		}
+
+		copies := rdscheckCopies(destination, snapshots)
+		lastGood := lastGoodCopy(destination, copies)
+		remaining := len(copies)
+
 		for _, snapshot := range oldSnapshots {
 			if destination.CheckTag(*snapshot.DBSnapshotArn, "CreatedBy", "rdscheck") {
 
+				if reason := retained(destination, snapshot, &instance); reason != "" {
+					log.WithFields(log.Fields{
+						"Snapshot": *snapshot.DBSnapshotIdentifier,
+						"Reason":   reason,
+					}).Info("Snapshot retained")
+					continue
+				}
+
+				if reason := protected(snapshot, lastGood, remaining, &instance); reason != "" {
+					err := refuseDeletion(destination, snapshot, reason, remaining)
+					if err != nil {
+						log.WithError(err).Error("Could not update datadog status")
+						return err
+					}
+					continue
+				}
+
 				err := destination.PostDatadogChecks(snapshot, "rdscheck.status", "ok", "copy")
 				if err != nil {
 					log.WithError(err).Error("Could not update datadog status")
@@ -156,8 +357,189 @@ func clean(destination checks.DefaultChecks, doc checks.Doc) error {
 					}
 					return err
 				}
+				remaining--
+			}
+		}
+	}
+	return nil
+}
+
+// rdscheckCopies returns the snapshots created by rdscheck
+func rdscheckCopies(destination checks.DefaultChecks, snapshots []*rds.DBSnapshot) []*rds.DBSnapshot {
+	var copies []*rds.DBSnapshot
+	for _, snapshot := range snapshots {
+		if destination.CheckTag(*snapshot.DBSnapshotArn, "CreatedBy", "rdscheck") {
+			copies = append(copies, snapshot)
+		}
+	}
+	return copies
+}
+
+// lastGoodCopy returns the newest copy that passed its checks, or nil
+func lastGoodCopy(destination checks.DefaultChecks, copies []*rds.DBSnapshot) *rds.DBSnapshot {
+	for i := len(copies) - 1; i >= 0; i-- {
+		if destination.CheckTag(*copies[i].DBSnapshotArn, "Status", "tested") &&
+			destination.CheckTag(*copies[i].DBSnapshotArn, "ChecksFailed", "no") {
+			return copies[i]
+		}
+	}
+	return nil
+}
+
+// retained returns why a copy is kept past its retention, or an empty string:
+// copies under legal hold are never deleted and the ones that failed their checks
+// are kept forensic_retention days for investigation
+func retained(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) string {
+	hold := destination.GetTagValue(*snapshot.DBSnapshotArn, config.LegalHoldTag)
+	if hold != "" && hold != "false" {
+		return "legal_hold"
+	}
+	if instance.ForensicRetention > 0 && destination.CheckTag(*snapshot.DBSnapshotArn, "ChecksFailed", "yes") {
+		if snapshot.SnapshotCreateTime.After(time.Now().AddDate(0, 0, -instance.ForensicRetention)) {
+			return "forensic_retention"
+		}
+	}
+	return ""
+}
+
+// protected returns why a copy must not be deleted, or an empty string if it can be
+func protected(snapshot, lastGood *rds.DBSnapshot, remaining int, instance *checks.Instances) string {
+	if lastGood != nil && *snapshot.DBSnapshotArn == *lastGood.DBSnapshotArn {
+		return "last_good_copy"
+	}
+	if remaining <= minCopies(instance) {
+		return "min_copies"
+	}
+	return ""
+}
+
+// minCopies returns the number of copies always kept for an instance, 1 by default
+func minCopies(instance *checks.Instances) int {
+	if instance.MinCopies > 0 {
+		return instance.MinCopies
+	}
+	return 1
+}
+
+// refuseDeletion logs a deletion refused by the retention safeguards
+// and sends it to datadog as the rdscheck.retention check
+func refuseDeletion(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, reason string, remaining int) error {
+	log.WithFields(log.Fields{
+		"Snapshot":  *snapshot.DBSnapshotIdentifier,
+		"Reason":    reason,
+		"Remaining": remaining,
+	}).Error("Refused to delete snapshot")
+
+	return destination.PostDatadogChecks(snapshot, "rdscheck.retention", "critical", "copy")
+}
+
+// getOldSnapshots applies the retention of the instance, and the manual one
+// to the copies of manual snapshots when it is set
+func getOldSnapshots(destination checks.DefaultChecks, snapshots []*rds.DBSnapshot, instance *checks.Instances) ([]*rds.DBSnapshot, error) {
+	if instance.Manual.Retention == 0 {
+		return retention(destination, snapshots, instance)
+	}
+
+	var automated, manual []*rds.DBSnapshot
+	for _, snapshot := range snapshots {
+		if destination.GetTagValue(*snapshot.DBSnapshotArn, "SourceType") == "manual" {
+			manual = append(manual, snapshot)
+		} else {
+			automated = append(automated, snapshot)
+		}
+	}
+
+	oldSnapshots, err := retention(destination, automated, instance)
+	if err != nil {
+		return nil, err
+	}
+	oldManual, err := destination.GetOldSnapshots(manual, instance.Manual.Retention)
+	if err != nil {
+		return nil, err
+	}
+	return append(oldSnapshots, oldManual...), nil
+}
+
+// retention applies the grandfather-father-son retention of the instance if it is set,
+// otherwise its retention in days
+func retention(destination checks.DefaultChecks, snapshots []*rds.DBSnapshot, instance *checks.Instances) ([]*rds.DBSnapshot, error) {
+	if instance.GFS.Enabled() {
+		return destination.GetGFSOldSnapshots(snapshots, instance.GFS)
+	}
+	return destination.GetOldSnapshots(snapshots, instance.Retention)
+}
+
+// checkSourceEncryption reports the encryption violations of a source snapshot
+// and returns false if its copy can't be encrypted
+func checkSourceEncryption(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, instance *checks.Instances) (bool, error) {
+	if instance.KmsID == "" {
+		return false, reportViolation(destination, snapshot, "missing_kms_key", "critical")
+	}
+	if !*snapshot.Encrypted {
+		return true, reportViolation(destination, snapshot, "unencrypted_source", "warning")
+	}
+	return true, nil
+}
+
+// checkEncryption makes sure the copies of the instances enforcing encryption
+// are encrypted with their kms key
+func checkEncryption(destination checks.DefaultChecks, doc checks.Doc) error {
+	for _, instance := range doc.Instances {
+		kmsid := instance.KmsID
+		if instance.Vault.RoleArn != "" {
+			kmsid = instance.Vault.KmsID
+		}
+		if !instance.Encrypt || kmsid == "" {
+			continue
+		}
+		checks.SetVaultSessions(destination, instance)
+
+		keyArn, err := destination.GetKmsKeyArn(kmsid)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"KMS Key":      kmsid,
+			}).WithError(err).Error("Could not describe the kms key")
+			return err
+		}
+
+		snapshots, err := destination.GetSnapshots(instance.Name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"AWS Region":   instance.Destination,
+			}).WithError(err).Error("Could not get snapshots")
+			return err
+		}
+
+		for _, snapshot := range snapshots {
+			if !destination.CheckTag(*snapshot.DBSnapshotArn, "CreatedBy", "rdscheck") {
+				continue
+			}
+
+			switch {
+			case !*snapshot.Encrypted:
+				err = reportViolation(destination, snapshot, "unencrypted_copy", "critical")
+			case snapshot.KmsKeyId == nil || *snapshot.KmsKeyId != keyArn:
+				err = reportViolation(destination, snapshot, "wrong_kms_key", "critical")
+			default:
+				err = destination.PostDatadogChecks(snapshot, "rdscheck.encryption", "ok", "copy")
+			}
+			if err != nil {
+				log.WithError(err).Error("Could not update datadog status")
+				return err
 			}
 		}
 	}
 	return nil
 }
+
+// reportViolation logs an encryption violation and sends it to datadog as the rdscheck.encryption check
+func reportViolation(destination checks.DefaultChecks, snapshot *rds.DBSnapshot, violation, status string) error {
+	log.WithFields(log.Fields{
+		"Snapshot":  *snapshot.DBSnapshotIdentifier,
+		"Violation": violation,
+	}).Warn("Encryption policy violated")
+
+	return destination.PostDatadogChecks(snapshot, "rdscheck.encryption", status, "copy")
+}
@@ -54,11 +54,16 @@ var snapshots = []*rds.DBSnapshot{
 	},
 }
 
-func (m *mockDefaultChecks) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string) error {
-	args := m.Called(snapshot, destination, kmsid)
+func (m *mockDefaultChecks) CopySnapshots(snapshot *rds.DBSnapshot, destination, kmsid, preSignedUrl, cleanArn string, encrypt bool) error {
+	args := m.Called(snapshot, destination, kmsid, encrypt)
 	return args.Error(0)
 }
 
+func (m *mockDefaultChecks) GetKmsKeyArn(kmsid string) (string, error) {
+	args := m.Called(kmsid)
+	return args.String(0), args.Error(1)
+}
+
 func (m *mockDefaultChecks) GetSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error) {
 	args := m.Called(DBInstanceIdentifier)
 	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
@@ -69,6 +74,11 @@ func (m *mockDefaultChecks) GetOldSnapshots(snapshots []*rds.DBSnapshot, retenti
 	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
 }
 
+func (m *mockDefaultChecks) GetGFSOldSnapshots(snapshots []*rds.DBSnapshot, gfs checks.GFS) ([]*rds.DBSnapshot, error) {
+	args := m.Called(snapshots, gfs)
+	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
+}
+
 func (m *mockDefaultChecks) DeleteOldSnapshot(snapshot *rds.DBSnapshot) error {
 	args := m.Called(snapshot)
 	return args.Error(0)
@@ -78,6 +88,45 @@ func (m *mockDefaultChecks) SetSessions(region string) {
 	m.Called(region)
 }
 
+func (m *mockDefaultChecks) MatchManual(snapshot *rds.DBSnapshot, manual checks.Manual) bool {
+	args := m.Called(snapshot, manual)
+	return args.Bool(0)
+}
+
+func (m *mockDefaultChecks) GetTagValue(arn, key string) string {
+	args := m.Called(arn, key)
+	return args.String(0)
+}
+
+func (m *mockDefaultChecks) SetRoleSessions(region, roleArn string) {
+	m.Called(region, roleArn)
+}
+
+func (m *mockDefaultChecks) GetSnapshotStatus(DBSnapshotIdentifier string) string {
+	args := m.Called(DBSnapshotIdentifier)
+	return args.String(0)
+}
+
+func (m *mockDefaultChecks) GetSnapshot(DBSnapshotIdentifier string) (*rds.DBSnapshot, error) {
+	args := m.Called(DBSnapshotIdentifier)
+	return args.Get(0).(*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) ShareSnapshot(DBSnapshotIdentifier string, accounts []string) error {
+	args := m.Called(DBSnapshotIdentifier, accounts)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) UnshareSnapshot(DBSnapshotIdentifier string, accounts []string) error {
+	args := m.Called(DBSnapshotIdentifier, accounts)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) CopyVaultSnapshot(snapshot, staging *rds.DBSnapshot, kmsid, cleanArn string) error {
+	args := m.Called(snapshot, staging, kmsid, cleanArn)
+	return args.Error(0)
+}
+
 func (m *mockDefaultChecks) GetYamlFileFromS3(bucket string, key string) (io.Reader, error) {
 	args := m.Called(bucket, key)
 	return args.Get(0).(io.Reader), args.Error(1)
@@ -127,9 +176,9 @@ func TestCopy(t *testing.T) {
 	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	c.On("CleanArn", mock.Anything).Return("test")
 	c.On("PreSignUrl", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("https://url.local", nil)
-	c.On("CopySnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CopySnapshots", mock.Anything, mock.Anything, mock.Anything, false).Return(nil)
 
-	err := copy(c, c, doc)
+	err := copy(c, c, c, doc)
 
 	assert.Nil(t, err)
 	c.AssertExpectations(t)
@@ -142,6 +191,7 @@ func TestClean(t *testing.T) {
 	c.On("GetSnapshots", mock.Anything).Return(snapshots, nil)
 	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	c.On("CheckTag", mock.Anything, mock.Anything, mock.Anything).Return(true)
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
 	c.On("GetOldSnapshots", mock.Anything, mock.Anything).Return(snapshots, nil)
 	c.On("DeleteOldSnapshot", mock.Anything).Return(nil)
 
@@ -150,3 +200,557 @@ func TestClean(t *testing.T) {
 	assert.Nil(t, err)
 	c.AssertExpectations(t)
 }
+
+var encryptDoc = checks.Doc{
+	Instances: []checks.Instances{
+		checks.Instances{
+			Name:        "test",
+			Destination: "us-east-1",
+			KmsID:       "alias/rdscheck",
+			Encrypt:     true,
+		},
+	},
+}
+
+func TestCopyEncryptUnencryptedSource(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	unencrypted := []*rds.DBSnapshot{
+		&rds.DBSnapshot{
+			DBSnapshotIdentifier: aws.String("test"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:test"),
+			SnapshotType:         aws.String("automated"),
+			Encrypted:            aws.Bool(false),
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", mock.Anything).Return(unencrypted, nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.encryption", "warning", "copy").Return(nil)
+	c.On("CleanArn", mock.Anything).Return("test")
+	c.On("CopySnapshots", unencrypted[0], "us-east-1", "alias/rdscheck", true).Return(nil)
+
+	err := copy(c, c, c, encryptDoc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "PreSignUrl", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCopyEncryptMissingKmsKey(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	doc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:    "test",
+				Encrypt: true,
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", mock.Anything).Return(snapshots, nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.encryption", "critical", "copy").Return(nil)
+
+	err := copy(c, c, c, doc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "CopySnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCheckEncryption(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	copies := []*rds.DBSnapshot{
+		&rds.DBSnapshot{
+			DBSnapshotIdentifier: aws.String("good"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:good"),
+			Encrypted:            aws.Bool(true),
+			KmsKeyId:             aws.String("arn:aws:kms:us-east-1:123456789012:key/good"),
+		},
+		&rds.DBSnapshot{
+			DBSnapshotIdentifier: aws.String("wrong"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:wrong"),
+			Encrypted:            aws.Bool(true),
+			KmsKeyId:             aws.String("arn:aws:kms:us-east-1:123456789012:key/wrong"),
+		},
+		&rds.DBSnapshot{
+			DBSnapshotIdentifier: aws.String("unencrypted"),
+			DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:unencrypted"),
+			Encrypted:            aws.Bool(false),
+		},
+	}
+
+	c.On("SetSessions", "us-east-1").Return()
+	c.On("GetKmsKeyArn", "alias/rdscheck").Return("arn:aws:kms:us-east-1:123456789012:key/good", nil)
+	c.On("GetSnapshots", "test").Return(copies, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("PostDatadogChecks", copies[0], "rdscheck.encryption", "ok", "copy").Return(nil)
+	c.On("PostDatadogChecks", copies[1], "rdscheck.encryption", "critical", "copy").Return(nil)
+	c.On("PostDatadogChecks", copies[2], "rdscheck.encryption", "critical", "copy").Return(nil)
+
+	err := checkEncryption(c, encryptDoc)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
+
+var vaultDoc = checks.Doc{
+	Instances: []checks.Instances{
+		checks.Instances{
+			Name:        "test",
+			Destination: "us-east-1",
+			KmsID:       "alias/rdscheck-shared",
+			Vault: checks.Vault{
+				RoleArn: "arn:aws:iam::999999999999:role/rdscheck-vault",
+				KmsID:   "alias/rdscheck-vault",
+			},
+		},
+	},
+}
+
+var staging = &rds.DBSnapshot{
+	Status:               aws.String("available"),
+	DBSnapshotIdentifier: aws.String("test-vault"),
+	DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:test-vault"),
+}
+
+func TestCopyToVaultStaging(t *testing.T) {
+	source := &mockDefaultChecks{}
+	destination := &mockDefaultChecks{}
+	vault := &mockDefaultChecks{}
+
+	source.On("SetSessions", mock.Anything).Return()
+	source.On("GetSnapshots", "test").Return(snapshots[:1], nil)
+	source.On("PreSignUrl", "us-east-1", mock.Anything, "alias/rdscheck-shared", "test-vault").Return("https://url.local", nil)
+	destination.On("SetSessions", "us-east-1").Return()
+	destination.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	destination.On("CleanArn", mock.Anything).Return("test")
+	destination.On("GetSnapshot", "test-vault").Return((*rds.DBSnapshot)(nil), nil)
+	destination.On("CopySnapshots", snapshots[0], "us-east-1", "alias/rdscheck-shared", false).Return(nil)
+	vault.On("SetRoleSessions", "us-east-1", "arn:aws:iam::999999999999:role/rdscheck-vault").Return()
+	vault.On("GetSnapshotStatus", "test").Return("")
+
+	err := copy(source, destination, vault, vaultDoc)
+
+	assert.Nil(t, err)
+	source.AssertExpectations(t)
+	destination.AssertExpectations(t)
+	vault.AssertExpectations(t)
+}
+
+func TestCopyToVaultShare(t *testing.T) {
+	source := &mockDefaultChecks{}
+	destination := &mockDefaultChecks{}
+	vault := &mockDefaultChecks{}
+
+	source.On("SetSessions", mock.Anything).Return()
+	source.On("GetSnapshots", "test").Return(snapshots[:1], nil)
+	destination.On("SetSessions", "us-east-1").Return()
+	destination.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	destination.On("CleanArn", mock.Anything).Return("test")
+	destination.On("GetSnapshot", "test-vault").Return(staging, nil)
+	destination.On("ShareSnapshot", "test-vault", []string{"999999999999"}).Return(nil)
+	vault.On("SetRoleSessions", "us-east-1", "arn:aws:iam::999999999999:role/rdscheck-vault").Return()
+	vault.On("GetSnapshotStatus", "test").Return("")
+	vault.On("CopyVaultSnapshot", snapshots[0], staging, "alias/rdscheck-vault", "test").Return(nil)
+
+	err := copy(source, destination, vault, vaultDoc)
+
+	assert.Nil(t, err)
+	source.AssertExpectations(t)
+	destination.AssertExpectations(t)
+	vault.AssertExpectations(t)
+}
+
+func TestCopyToVaultUnshare(t *testing.T) {
+	source := &mockDefaultChecks{}
+	destination := &mockDefaultChecks{}
+	vault := &mockDefaultChecks{}
+
+	source.On("SetSessions", mock.Anything).Return()
+	source.On("GetSnapshots", "test").Return(snapshots[:1], nil)
+	destination.On("SetSessions", "us-east-1").Return()
+	destination.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	destination.On("CleanArn", mock.Anything).Return("test")
+	destination.On("GetSnapshot", "test-vault").Return(staging, nil)
+	destination.On("UnshareSnapshot", "test-vault", []string{"999999999999"}).Return(nil)
+	destination.On("DeleteOldSnapshot", staging).Return(nil)
+	vault.On("SetRoleSessions", "us-east-1", "arn:aws:iam::999999999999:role/rdscheck-vault").Return()
+	vault.On("GetSnapshotStatus", "test").Return("available")
+
+	err := copy(source, destination, vault, vaultDoc)
+
+	assert.Nil(t, err)
+	destination.AssertNotCalled(t, "ShareSnapshot", mock.Anything, mock.Anything)
+	source.AssertExpectations(t)
+	destination.AssertExpectations(t)
+	vault.AssertExpectations(t)
+}
+
+func TestCleanVault(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetRoleSessions", "us-east-1", "arn:aws:iam::999999999999:role/rdscheck-vault").Return()
+	c.On("GetSnapshots", "test").Return(snapshots, nil)
+	c.On("GetOldSnapshots", snapshots, 0).Return([]*rds.DBSnapshot{}, nil)
+
+	err := clean(c, vaultDoc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "SetSessions", mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCopyFromSourceAccount(t *testing.T) {
+	source := &mockDefaultChecks{}
+	destination := &mockDefaultChecks{}
+
+	accountDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:         "test",
+				SourceRegion: "eu-west-1",
+				RoleArn:      "arn:aws:iam::210987654321:role/rdscheck",
+				Destination:  "eu-central-1",
+				KmsID:        "alias/rdscheck",
+			},
+		},
+	}
+
+	source.On("SetRoleSessions", "eu-west-1", "arn:aws:iam::210987654321:role/rdscheck").Return()
+	source.On("GetSnapshots", "test").Return(snapshots[:1], nil)
+	source.On("PreSignUrl", "eu-central-1", mock.Anything, "alias/rdscheck", "test").Return("https://url.local", nil)
+	destination.On("SetRoleSessions", "eu-central-1", "arn:aws:iam::210987654321:role/rdscheck").Return()
+	destination.On("PostDatadogChecks", mock.Anything, "rdscheck.status", "ok", "copy").Return(nil)
+	destination.On("CleanArn", mock.Anything).Return("test")
+	destination.On("CopySnapshots", snapshots[0], "eu-central-1", "alias/rdscheck", false).Return(nil)
+
+	err := copy(source, destination, &mockDefaultChecks{}, accountDoc)
+
+	assert.Nil(t, err)
+	source.AssertNotCalled(t, "SetSessions", mock.Anything)
+	source.AssertExpectations(t)
+	destination.AssertExpectations(t)
+}
+
+var manualSnapshots = []*rds.DBSnapshot{
+	&rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("pre-migration"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -20)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:pre-migration"),
+		SnapshotType:         aws.String("manual"),
+		Encrypted:            aws.Bool(false),
+	},
+	&rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("adhoc"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -5)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:adhoc"),
+		SnapshotType:         aws.String("manual"),
+		Encrypted:            aws.Bool(false),
+	},
+}
+
+var manualDoc = checks.Doc{
+	Instances: []checks.Instances{
+		checks.Instances{
+			Name:          "test",
+			Destination:   "us-east-1",
+			Retention:     7,
+			SnapshotTypes: []string{"manual"},
+			Manual: checks.Manual{
+				Prefix:    "pre-migration",
+				Retention: 90,
+			},
+		},
+	},
+}
+
+func TestCopyManualSnapshots(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	all := append([]*rds.DBSnapshot{snapshots[0]}, manualSnapshots...)
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(all, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(false)
+	c.On("MatchManual", manualSnapshots[0], manualDoc.Instances[0].Manual).Return(true)
+	c.On("MatchManual", manualSnapshots[1], manualDoc.Instances[0].Manual).Return(false)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CleanArn", manualSnapshots[0]).Return("pre-migration")
+	c.On("CopySnapshots", manualSnapshots[0], "us-east-1", "", false).Return(nil)
+
+	err := copy(c, c, c, manualDoc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "CopySnapshots", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCopyExpiredSnapshots(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	// clean already deleted the copies of these sources, they must not be copied again
+	old := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("pre-migration-old"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -120)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:pre-migration-old"),
+		SnapshotType:         aws.String("manual"),
+		Encrypted:            aws.Bool(false),
+	}
+
+	expiredDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:          "test",
+				Destination:   "us-east-1",
+				Retention:     7,
+				SnapshotTypes: []string{"automated", "manual"},
+				Manual: checks.Manual{
+					Prefix:    "pre-migration",
+					Retention: 90,
+				},
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return([]*rds.DBSnapshot{snapshots[0], old, manualSnapshots[0]}, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(false)
+	c.On("MatchManual", mock.Anything, expiredDoc.Instances[0].Manual).Return(true)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CleanArn", manualSnapshots[0]).Return("pre-migration")
+	c.On("CopySnapshots", manualSnapshots[0], "us-east-1", "", false).Return(nil)
+
+	err := copy(c, c, c, expiredDoc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "CopySnapshots", 1)
+	c.AssertNotCalled(t, "CopySnapshots", old, mock.Anything, mock.Anything, mock.Anything)
+	c.AssertNotCalled(t, "CopySnapshots", snapshots[0], mock.Anything, mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCleanManualRetention(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	copies := []*rds.DBSnapshot{snapshots[0], manualSnapshots[0]}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(copies, nil)
+	c.On("GetTagValue", *snapshots[0].DBSnapshotArn, "SourceType").Return("automated")
+	c.On("GetTagValue", *manualSnapshots[0].DBSnapshotArn, "SourceType").Return("manual")
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{snapshots[0]}, 7).Return([]*rds.DBSnapshot{snapshots[0]}, nil)
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{manualSnapshots[0]}, 90).Return([]*rds.DBSnapshot{}, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
+	c.On("CheckTag", mock.Anything, "Status", "tested").Return(false)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("DeleteOldSnapshot", snapshots[0]).Return(nil)
+
+	err := clean(c, manualDoc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "DeleteOldSnapshot", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCleanGFS(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	gfsDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "test",
+				Destination: "us-east-1",
+				Retention:   7,
+				GFS: checks.GFS{
+					Daily:   7,
+					Weekly:  4,
+					Monthly: 12,
+				},
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(snapshots, nil)
+	c.On("GetGFSOldSnapshots", snapshots, gfsDoc.Instances[0].GFS).Return([]*rds.DBSnapshot{snapshots[0]}, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
+	c.On("CheckTag", mock.Anything, "Status", "tested").Return(false)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("DeleteOldSnapshot", snapshots[0]).Return(nil)
+
+	err := clean(c, gfsDoc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "GetOldSnapshots", mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestCopyGFS(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	gfsDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "test",
+				Destination: "us-east-1",
+				Retention:   7,
+				GFS: checks.GFS{
+					Daily: 1,
+				},
+			},
+		},
+	}
+
+	// the copy of snapshots[0] was pruned by clean while its source still exists
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(snapshots, nil)
+	c.On("GetGFSOldSnapshots", snapshots, gfsDoc.Instances[0].GFS).Return([]*rds.DBSnapshot{snapshots[0]}, nil)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CleanArn", snapshots[1]).Return("test-2")
+	c.On("PreSignUrl", "us-east-1", *snapshots[1].DBSnapshotArn, "", "test-2").Return("https://url.local", nil)
+	c.On("CopySnapshots", snapshots[1], "us-east-1", "", false).Return(nil)
+
+	err := copy(c, c, c, gfsDoc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "CopySnapshots", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCleanKeepsLastGoodCopy(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(snapshots, nil)
+	c.On("GetOldSnapshots", snapshots, 0).Return(snapshots, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
+	c.On("CheckTag", *snapshots[1].DBSnapshotArn, "Status", "tested").Return(false)
+	c.On("CheckTag", *snapshots[0].DBSnapshotArn, "Status", "tested").Return(true)
+	c.On("CheckTag", *snapshots[0].DBSnapshotArn, "ChecksFailed", "no").Return(true)
+	c.On("PostDatadogChecks", snapshots[0], "rdscheck.retention", "critical", "copy").Return(nil)
+	c.On("PostDatadogChecks", snapshots[1], "rdscheck.status", "ok", "copy").Return(nil)
+	c.On("DeleteOldSnapshot", snapshots[1]).Return(nil)
+
+	err := clean(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "DeleteOldSnapshot", snapshots[0])
+	c.AssertExpectations(t)
+}
+
+func TestCleanMinCopies(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	minDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "test",
+				Destination: "us-east-1",
+				MinCopies:   2,
+			},
+		},
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(snapshots, nil)
+	c.On("GetOldSnapshots", snapshots, 0).Return(snapshots, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
+	c.On("CheckTag", mock.Anything, "Status", "tested").Return(false)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.retention", "critical", "copy").Return(nil)
+
+	err := clean(c, minDoc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "DeleteOldSnapshot", mock.Anything)
+	c.AssertNumberOfCalls(t, "PostDatadogChecks", 2)
+	c.AssertExpectations(t)
+}
+
+func TestCleanRetained(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	forensicDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:              "test",
+				Destination:       "us-east-1",
+				ForensicRetention: 30,
+			},
+		},
+	}
+
+	held := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("held"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(-2, 0, 0)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:held"),
+	}
+	failed := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("failed"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -10)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:failed"),
+	}
+	expired := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("expired"),
+		SnapshotCreateTime:   aws.Time(time.Now().AddDate(0, 0, -40)),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-east-1:123456789012:snapshot:expired"),
+	}
+	copies := []*rds.DBSnapshot{expired, held, failed, snapshots[1]}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return(copies, nil)
+	c.On("GetOldSnapshots", copies, 0).Return([]*rds.DBSnapshot{expired, held, failed}, nil)
+	c.On("CheckTag", mock.Anything, "CreatedBy", "rdscheck").Return(true)
+	c.On("CheckTag", mock.Anything, "Status", "tested").Return(false)
+	c.On("GetTagValue", *held.DBSnapshotArn, "LegalHold").Return("true")
+	c.On("GetTagValue", mock.Anything, "LegalHold").Return("")
+	c.On("CheckTag", mock.Anything, "ChecksFailed", "yes").Return(true)
+	c.On("PostDatadogChecks", expired, "rdscheck.status", "ok", "copy").Return(nil)
+	c.On("DeleteOldSnapshot", expired).Return(nil)
+
+	err := clean(c, forensicDoc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "DeleteOldSnapshot", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCopyScheduledSnapshots(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	scheduled := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rdscheck-scheduled-test-2020-06-30-0230"),
+		SnapshotCreateTime:   aws.Time(time.Now()),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rdscheck-scheduled-test-2020-06-30-0230"),
+		SnapshotType:         aws.String("manual"),
+		Encrypted:            aws.Bool(false),
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return([]*rds.DBSnapshot{scheduled}, nil)
+	c.On("CheckTag", *scheduled.DBSnapshotArn, "ScheduledBy", "rdscheck").Return(true)
+	c.On("PostDatadogChecks", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	c.On("CleanArn", scheduled).Return("rdscheck-scheduled-test-2020-06-30-0230")
+	c.On("CopySnapshots", scheduled, mock.Anything, mock.Anything, false).Return(nil)
+
+	err := copy(c, c, c, doc)
+
+	assert.Nil(t, err)
+	c.AssertExpectations(t)
+}
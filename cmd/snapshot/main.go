@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	log "github.com/sirupsen/logrus"
+	"github.com/techdroplabs/rdscheck/checks"
+	"github.com/techdroplabs/rdscheck/config"
+)
+
+func main() {
+	log.AddHook(&checks.RedactHook{})
+	lambda.Start(run)
+}
+
+func run() {
+	checks.ResetPlan()
+
+	source := checks.New()
+
+	doc, err := getDoc(source)
+	if err != nil {
+		log.WithError(err).Error("getDoc returned:")
+		os.Exit(1)
+	}
+
+	err = snapshot(source, doc, time.Now())
+	if err != nil {
+		log.WithError(err).Error("snapshot returned:")
+		os.Exit(1)
+	}
+
+	err = clean(source, doc)
+	if err != nil {
+		log.WithError(err).Error("clean returned:")
+		os.Exit(1)
+	}
+
+	checks.LogPlan()
+}
+
+func getDoc(source checks.DefaultChecks) (checks.Doc, error) {
+	source.SetSessions(config.AWSRegionSource)
+
+	doc := checks.Doc{}
+
+	yaml, err := source.GetYamlFileFromS3(config.S3Bucket, config.S3Key)
+	if err != nil {
+		log.WithError(err).Error("Could not get the yaml file from s3")
+		return doc, err
+	}
+
+	doc, err = source.UnmarshalYamlFile(yaml)
+	if err != nil {
+		log.WithError(err).Error("Could not unmarshal yaml file")
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+// snapshot takes the snapshots of the instances due at now.
+// The copy command picks them up like the automated snapshots.
+func snapshot(source checks.DefaultChecks, doc checks.Doc, now time.Time) error {
+	taken := map[string]bool{}
+	for _, instance := range doc.Instances {
+		slot, due := checks.DueSlot(instance.Schedule, now)
+		if !due {
+			continue
+		}
+
+		identifier := checks.ScheduledIdentifier(instance.Name, slot)
+		// instances with several destinations are listed once per destination
+		if taken[identifier] {
+			continue
+		}
+		taken[identifier] = true
+
+		checks.SetSourceSessions(source, instance)
+		if source.GetSnapshotStatus(identifier) != "" {
+			continue
+		}
+
+		scheduled := &rds.DBSnapshot{
+			DBInstanceIdentifier: aws.String(instance.Name),
+			DBSnapshotIdentifier: aws.String(identifier),
+		}
+
+		err := source.CreateScheduledSnapshot(instance.Name, identifier)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"Snapshot":     identifier,
+			}).WithError(err).Error("Could not create scheduled snapshot")
+
+			err := source.PostDatadogChecks(scheduled, "rdscheck.snapshot", "critical", "snapshot")
+			if err != nil {
+				log.WithError(err).Error("Could not update datadog status")
+				return err
+			}
+			continue
+		}
+
+		err = source.PostDatadogChecks(scheduled, "rdscheck.snapshot", "ok", "snapshot")
+		if err != nil {
+			log.WithError(err).Error("Could not update datadog status")
+			return err
+		}
+	}
+	return nil
+}
+
+// clean deletes the scheduled snapshots of the source instances older than
+// schedule.retention days, or retention days if it is not set. They are kept if neither is set.
+// Their copies follow the retention of the copy command.
+func clean(source checks.DefaultChecks, doc checks.Doc) error {
+	cleaned := map[string]bool{}
+	for _, instance := range doc.Instances {
+		if len(instance.Schedule.At) == 0 || cleaned[instance.Name] {
+			continue
+		}
+		cleaned[instance.Name] = true
+
+		retention := instance.Schedule.Retention
+		if retention == 0 {
+			retention = instance.Retention
+		}
+		if retention == 0 {
+			continue
+		}
+
+		checks.SetSourceSessions(source, instance)
+
+		snapshots, err := source.GetSnapshots(instance.Name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"RDS Instance": instance.Name,
+				"AWS Region":   checks.SourceRegion(instance),
+			}).WithError(err).Error("Could not get snapshots")
+			return err
+		}
+
+		var scheduled []*rds.DBSnapshot
+		for _, s := range snapshots {
+			if checks.IsScheduled(s) && source.CheckTag(*s.DBSnapshotArn, "ScheduledBy", "rdscheck") {
+				scheduled = append(scheduled, s)
+			}
+		}
+
+		oldSnapshots, err := source.GetOldSnapshots(scheduled, retention)
+		if err != nil {
+			log.WithError(err).Error("Could not get old snapshots")
+			return err
+		}
+
+		for _, s := range oldSnapshots {
+			hold := source.GetTagValue(*s.DBSnapshotArn, config.LegalHoldTag)
+			if hold != "" && hold != "false" {
+				log.WithFields(log.Fields{
+					"Snapshot": *s.DBSnapshotIdentifier,
+					"Reason":   "legal_hold",
+				}).Info("Snapshot retained")
+				continue
+			}
+
+			err = source.DeleteOldSnapshot(s)
+			if err != nil {
+				log.WithError(err).Error("Could not delete old snapshots")
+
+				if err := source.PostDatadogChecks(s, "rdscheck.snapshot", "critical", "snapshot"); err != nil {
+					log.WithError(err).Error("Could not update datadog status")
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/techdroplabs/rdscheck/checks"
+)
+
+type mockDefaultChecks struct {
+	checks.DefaultChecks
+	mock.Mock
+}
+
+var doc = checks.Doc{
+	Instances: []checks.Instances{
+		checks.Instances{
+			Name:        "test",
+			Destination: "us-east-1",
+			Retention:   7,
+			Schedule: checks.Schedule{
+				At:        []string{"02:30"},
+				Retention: 2,
+			},
+		},
+		checks.Instances{
+			Name:        "test",
+			Destination: "eu-west-1",
+			Schedule: checks.Schedule{
+				At: []string{"02:30"},
+			},
+		},
+		checks.Instances{
+			Name:        "unscheduled",
+			Destination: "us-east-1",
+		},
+	},
+}
+
+var now = time.Date(2020, 6, 30, 2, 40, 0, 0, time.UTC)
+
+func (m *mockDefaultChecks) SetSessions(region string) {
+	m.Called(region)
+}
+
+func (m *mockDefaultChecks) GetYamlFileFromS3(bucket string, key string) (io.Reader, error) {
+	args := m.Called(bucket, key)
+	return args.Get(0).(io.Reader), args.Error(1)
+}
+
+func (m *mockDefaultChecks) UnmarshalYamlFile(body io.Reader) (checks.Doc, error) {
+	args := m.Called(body)
+	return args.Get(0).(checks.Doc), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetSnapshotStatus(DBSnapshotIdentifier string) string {
+	args := m.Called(DBSnapshotIdentifier)
+	return args.String(0)
+}
+
+func (m *mockDefaultChecks) CreateScheduledSnapshot(DBInstanceIdentifier, DBSnapshotIdentifier string) error {
+	args := m.Called(DBInstanceIdentifier, DBSnapshotIdentifier)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) PostDatadogChecks(snapshot *rds.DBSnapshot, metricName, status, cmdName string) error {
+	args := m.Called(snapshot, metricName, status, cmdName)
+	return args.Error(0)
+}
+
+func (m *mockDefaultChecks) GetSnapshots(DBInstanceIdentifier string) ([]*rds.DBSnapshot, error) {
+	args := m.Called(DBInstanceIdentifier)
+	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) CheckTag(arn string, key string, value string) bool {
+	args := m.Called(arn, key, value)
+	return args.Bool(0)
+}
+
+func (m *mockDefaultChecks) GetOldSnapshots(snapshots []*rds.DBSnapshot, retention int) ([]*rds.DBSnapshot, error) {
+	args := m.Called(snapshots, retention)
+	return args.Get(0).([]*rds.DBSnapshot), args.Error(1)
+}
+
+func (m *mockDefaultChecks) GetTagValue(arn, key string) string {
+	args := m.Called(arn, key)
+	return args.String(0)
+}
+
+func (m *mockDefaultChecks) DeleteOldSnapshot(snapshot *rds.DBSnapshot) error {
+	args := m.Called(snapshot)
+	return args.Error(0)
+}
+
+func TestGetDoc(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	yaml, _ := ioutil.ReadFile("../../example/checks.yml")
+	input := bytes.NewReader(yaml)
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetYamlFileFromS3", mock.Anything, mock.Anything).Return(input, nil)
+	c.On("UnmarshalYamlFile", mock.Anything).Return(doc, nil)
+
+	value, err := getDoc(c)
+
+	assert.Nil(t, err)
+	assert.Equal(t, value, doc)
+}
+
+func TestSnapshot(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshotStatus", "rdscheck-scheduled-test-2020-06-30-0230").Return("")
+	c.On("CreateScheduledSnapshot", "test", "rdscheck-scheduled-test-2020-06-30-0230").Return(nil)
+	c.On("PostDatadogChecks", mock.Anything, "rdscheck.snapshot", "ok", "snapshot").Return(nil)
+
+	err := snapshot(c, doc, now)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "CreateScheduledSnapshot", 1)
+	c.AssertExpectations(t)
+}
+
+func TestSnapshotAlreadyTaken(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshotStatus", "rdscheck-scheduled-test-2020-06-30-0230").Return("creating")
+
+	err := snapshot(c, doc, now)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "CreateScheduledSnapshot", mock.Anything, mock.Anything)
+	c.AssertExpectations(t)
+}
+
+func TestSnapshotNotDue(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	err := snapshot(c, doc, now.Add(2*time.Hour))
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "SetSessions", mock.Anything)
+}
+
+func TestClean(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	scheduled := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rdscheck-scheduled-test-2020-06-27-0230"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rdscheck-scheduled-test-2020-06-27-0230"),
+		SnapshotType:         aws.String("manual"),
+	}
+	automated := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rds:test-2020-06-27-03-00"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rds:test-2020-06-27-03-00"),
+		SnapshotType:         aws.String("automated"),
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return([]*rds.DBSnapshot{automated, scheduled}, nil)
+	c.On("CheckTag", *scheduled.DBSnapshotArn, "ScheduledBy", "rdscheck").Return(true)
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{scheduled}, 2).Return([]*rds.DBSnapshot{scheduled}, nil)
+	c.On("GetTagValue", *scheduled.DBSnapshotArn, "LegalHold").Return("")
+	c.On("DeleteOldSnapshot", scheduled).Return(nil)
+
+	err := clean(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "GetSnapshots", 1)
+	c.AssertExpectations(t)
+}
+
+func TestCleanLegalHold(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	held := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rdscheck-scheduled-test-2020-06-26-0230"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rdscheck-scheduled-test-2020-06-26-0230"),
+		SnapshotType:         aws.String("manual"),
+	}
+	released := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rdscheck-scheduled-test-2020-06-27-0230"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rdscheck-scheduled-test-2020-06-27-0230"),
+		SnapshotType:         aws.String("manual"),
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return([]*rds.DBSnapshot{held, released}, nil)
+	c.On("CheckTag", mock.Anything, "ScheduledBy", "rdscheck").Return(true)
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{held, released}, 2).Return([]*rds.DBSnapshot{held, released}, nil)
+	c.On("GetTagValue", *held.DBSnapshotArn, "LegalHold").Return("case-42")
+	c.On("GetTagValue", *released.DBSnapshotArn, "LegalHold").Return("false")
+	c.On("DeleteOldSnapshot", released).Return(nil)
+
+	err := clean(c, doc)
+
+	assert.Nil(t, err)
+	c.AssertNumberOfCalls(t, "DeleteOldSnapshot", 1)
+	c.AssertNotCalled(t, "DeleteOldSnapshot", held)
+	c.AssertExpectations(t)
+}
+
+func TestCleanDeleteError(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	scheduled := &rds.DBSnapshot{
+		Status:               aws.String("available"),
+		DBSnapshotIdentifier: aws.String("rdscheck-scheduled-test-2020-06-27-0230"),
+		DBSnapshotArn:        aws.String("arn:aws:rds:us-west-2:123456789012:snapshot:rdscheck-scheduled-test-2020-06-27-0230"),
+		SnapshotType:         aws.String("manual"),
+	}
+
+	c.On("SetSessions", mock.Anything).Return()
+	c.On("GetSnapshots", "test").Return([]*rds.DBSnapshot{scheduled}, nil)
+	c.On("CheckTag", *scheduled.DBSnapshotArn, "ScheduledBy", "rdscheck").Return(true)
+	c.On("GetOldSnapshots", []*rds.DBSnapshot{scheduled}, 2).Return([]*rds.DBSnapshot{scheduled}, nil)
+	c.On("GetTagValue", *scheduled.DBSnapshotArn, "LegalHold").Return("")
+	c.On("DeleteOldSnapshot", scheduled).Return(errors.New("InvalidDBSnapshotState"))
+	c.On("PostDatadogChecks", scheduled, "rdscheck.snapshot", "critical", "snapshot").Return(nil)
+
+	err := clean(c, doc)
+
+	assert.EqualError(t, err, "InvalidDBSnapshotState")
+	c.AssertExpectations(t)
+}
+
+func TestCleanWithoutRetention(t *testing.T) {
+	c := &mockDefaultChecks{}
+
+	gfsDoc := checks.Doc{
+		Instances: []checks.Instances{
+			checks.Instances{
+				Name:        "test",
+				Destination: "us-east-1",
+				GFS: checks.GFS{
+					Daily:  7,
+					Weekly: 4,
+				},
+				Schedule: checks.Schedule{
+					At: []string{"02:30"},
+				},
+			},
+		},
+	}
+
+	err := clean(c, gfsDoc)
+
+	assert.Nil(t, err)
+	c.AssertNotCalled(t, "GetSnapshots", mock.Anything)
+	c.AssertNotCalled(t, "GetOldSnapshots", mock.Anything, mock.Anything)
+	c.AssertNotCalled(t, "DeleteOldSnapshot", mock.Anything)
+}
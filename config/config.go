@@ -16,4 +16,12 @@ var (
 	SubnetIds        = strings.Split(utils.GetEnvString("AWS_SUBNETS_IDS", ""), ",")
 	DDApiKey         = utils.GetEnvString("DD_API_KEY", "")
 	DDAplicationKey  = utils.GetEnvString("DD_APP_KEY", "")
+	SSLMode          = utils.GetEnvString("DB_SSLMODE", "verify-full")
+	RDSCABundle      = utils.GetEnvString("RDS_CA_BUNDLE", "/var/task/rds-ca-bundle.pem")
+	LegalHoldTag     = utils.GetEnvString("LEGAL_HOLD_TAG", "LegalHold")
+	DryRun           = utils.GetEnvBool("DRY_RUN", false)
+
+	// The point in time restores run in the source region, AWS_SG_IDS and AWS_SUBNETS_IDS are in the destination region
+	PITRSecurityGroupIds = strings.Split(utils.GetEnvString("AWS_PITR_SG_IDS", utils.GetEnvString("AWS_SG_IDS", "")), ",")
+	PITRSubnetIds        = strings.Split(utils.GetEnvString("AWS_PITR_SUBNETS_IDS", utils.GetEnvString("AWS_SUBNETS_IDS", "")), ",")
 )
@@ -21,7 +21,7 @@ EOF
 
 resource "null_resource" "get_release" {
   provisioner "local-exec" {
-    command = "rm -rf ${path.module}/lambda-files && mkdir ${path.module}/lambda-files && wget -O ${path.module}/lambda-files/main https://github.com/techdroplabs/rdscheck/releases/download/${var.release_version}/${var.command} && chmod +x ${path.module}/lambda-files/main"
+    command = "rm -rf ${path.module}/lambda-files && mkdir ${path.module}/lambda-files && wget -O ${path.module}/lambda-files/main https://github.com/techdroplabs/rdscheck/releases/download/${var.release_version}/${var.command} && chmod +x ${path.module}/lambda-files/main && wget -O ${path.module}/lambda-files/rds-ca-bundle.pem ${var.rds_ca_bundle_url}"
   }
 
   # We do that so null_resource is called everytime we run terraform apply or plan
@@ -30,10 +30,11 @@ resource "null_resource" "get_release" {
   }
 }
 
+# The RDS certificate authorities bundle is shipped next to the binary to verify the restored instances certificates
 data "archive_file" "lambda_code" {
   type        = "zip"
-  source_file = "${path.module}/lambda-files/main"
-  output_path = "${path.module}/lambda-files/main.zip"
+  source_dir  = "${path.module}/lambda-files"
+  output_path = "${path.module}/lambda-code.zip"
   depends_on  = [null_resource.get_release]
 }
 
@@ -57,7 +58,7 @@ resource "aws_lambda_function" "rdscheck_lambda_copy" {
 }
 
 resource "aws_lambda_function" "rdscheck_lambda_check" {
-  count            = var.command != "copy" ? 1 : 0
+  count            = var.command == "check" ? 1 : 0
   filename         = data.archive_file.lambda_code.output_path
   function_name    = "${var.command}-rdscheck"
   role             = aws_iam_role.rdscheck_iam_role.arn
@@ -81,7 +82,7 @@ resource "aws_lambda_function" "rdscheck_lambda_check" {
 }
 
 data "aws_iam_policy" "AWSLambdaVPCAccessExecutionRole" {
-  count = var.command != "copy" ? 1 : 0
+  count = var.command == "check" ? 1 : 0
   arn   = "arn:aws:iam::aws:policy/service-role/AWSLambdaVPCAccessExecutionRole"
 }
 
@@ -98,7 +99,7 @@ data "aws_iam_policy" "AmazonRDSFullAccess" {
 }
 
 resource "aws_iam_role_policy_attachment" "rdscheck_role_AWSLambdaVPCAccessExecutionRole_policy_attach" {
-  count      = var.command != "copy" ? 1 : 0
+  count      = var.command == "check" ? 1 : 0
   role       = aws_iam_role.rdscheck_iam_role.name
   policy_arn = data.aws_iam_policy.AWSLambdaVPCAccessExecutionRole[0].arn
 }
@@ -118,9 +119,123 @@ resource "aws_iam_role_policy_attachment" "rdscheck_role_AmazonRDSFullAccess_pol
   policy_arn = data.aws_iam_policy.AmazonRDSFullAccess.arn
 }
 
+resource "aws_iam_role_policy" "rdscheck_s3_write_policy" {
+  count = length(var.s3_write_buckets) > 0 ? 1 : 0
+  name  = "rdscheck_${var.command}_s3_write"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["s3:PutObject", "s3:DeleteObject"]
+        Effect   = "Allow"
+        Resource = [for bucket in var.s3_write_buckets : "arn:aws:s3:::${bucket}/*"]
+      },
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "rdscheck_secrets_policy" {
+  count = var.command == "check" ? 1 : 0
+  name  = "rdscheck_${var.command}_secrets"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = concat([
+      {
+        Action = [
+          "secretsmanager:CreateSecret",
+          "secretsmanager:PutSecretValue",
+          "secretsmanager:GetSecretValue",
+          "secretsmanager:DeleteSecret",
+          "secretsmanager:TagResource",
+        ]
+        Effect   = "Allow"
+        Resource = ["arn:aws:secretsmanager:*:*:secret:rdscheck/*"]
+      },
+      ], length(var.password_ref_arns) > 0 ? [
+      {
+        Action   = ["secretsmanager:GetSecretValue", "ssm:GetParameter"]
+        Effect   = "Allow"
+        Resource = var.password_ref_arns
+      },
+    ] : [])
+  })
+}
+
+resource "aws_iam_role_policy" "rdscheck_iam_db_auth_policy" {
+  count = var.command == "check" && length(var.iam_db_users) > 0 ? 1 : 0
+  name  = "rdscheck_${var.command}_iam_db_auth"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["rds-db:connect"]
+        Effect   = "Allow"
+        Resource = [for user in var.iam_db_users : "arn:aws:rds-db:*:*:dbuser:*/${user}"]
+      },
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "rdscheck_config_kms_policy" {
+  count = length(var.config_kms_key_arns) > 0 ? 1 : 0
+  name  = "rdscheck_${var.command}_config_kms"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["kms:Decrypt"]
+        Effect   = "Allow"
+        Resource = var.config_kms_key_arns
+      },
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "rdscheck_kms_describe_policy" {
+  count = var.command == "copy" ? 1 : 0
+  name  = "rdscheck_${var.command}_kms_describe"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["kms:DescribeKey"]
+        Effect   = "Allow"
+        Resource = ["*"]
+      },
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "rdscheck_assume_role_policy" {
+  count = length(var.role_arns) > 0 ? 1 : 0
+  name  = "rdscheck_${var.command}_assume_role"
+  role  = aws_iam_role.rdscheck_iam_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action   = ["sts:AssumeRole"]
+        Effect   = "Allow"
+        Resource = var.role_arns
+      },
+    ]
+  })
+}
+
 resource "aws_cloudwatch_event_rule" "rdscheck_rule_copy" {
-  count         = var.command != "check" ? 1 : 0
-  name          = "rdscheck_copy_rule"
+  count         = var.command == "copy" ? 1 : 0
+  name          = "rdscheck_${var.command}_rule"
   is_enabled    = true
   event_pattern = <<PATTERN
 {
@@ -135,36 +250,37 @@ PATTERN
 
 }
 
+# The check and snapshot commands run on a schedule, each with its own rule
 resource "aws_cloudwatch_event_rule" "rdscheck_rule_check" {
-  count               = var.command != "copy" ? 1 : 0
-  name                = "rdscheck_check_rule"
+  count               = var.command == "check" || var.command == "snapshot" ? 1 : 0
+  name                = "rdscheck_${var.command}_rule"
   schedule_expression = var.lambda_rate
   is_enabled          = true
 }
 
 resource "aws_cloudwatch_event_target" "rdscheck_target_check" {
-  count = var.command != "copy" ? 1 : 0
+  count = var.command == "check" || var.command == "snapshot" ? 1 : 0
   rule  = aws_cloudwatch_event_rule.rdscheck_rule_check[0].name
-  arn   = aws_lambda_function.rdscheck_lambda_check[0].arn
+  arn   = concat(aws_lambda_function.rdscheck_lambda_check.*.arn, aws_lambda_function.rdscheck_lambda_copy.*.arn)[0]
 }
 
 resource "aws_cloudwatch_event_target" "rdscheck_target_copy" {
-  count = var.command != "check" ? 1 : 0
+  count = var.command == "copy" ? 1 : 0
   rule  = aws_cloudwatch_event_rule.rdscheck_rule_copy[0].name
   arn   = aws_lambda_function.rdscheck_lambda_copy[0].arn
 }
 
 resource "aws_lambda_permission" "allow_cloudwatch_to_call_rdscheck_check" {
-  count         = var.command != "copy" ? 1 : 0
+  count         = var.command == "check" || var.command == "snapshot" ? 1 : 0
   statement_id  = "AllowExecutionFromCloudWatch"
   action        = "lambda:InvokeFunction"
-  function_name = aws_lambda_function.rdscheck_lambda_check[0].function_name
+  function_name = concat(aws_lambda_function.rdscheck_lambda_check.*.function_name, aws_lambda_function.rdscheck_lambda_copy.*.function_name)[0]
   principal     = "events.amazonaws.com"
   source_arn    = aws_cloudwatch_event_rule.rdscheck_rule_check[0].arn
 }
 
 resource "aws_lambda_permission" "allow_cloudwatch_to_call_rdscheck_copy" {
-  count         = var.command != "check" ? 1 : 0
+  count         = var.command == "copy" ? 1 : 0
   statement_id  = "AllowExecutionFromCloudWatch"
   action        = "lambda:InvokeFunction"
   function_name = aws_lambda_function.rdscheck_lambda_copy[0].function_name
@@ -198,3 +314,32 @@ variable "subnet_ids" {
   type    = list(string)
   default = []
 }
+
+variable "s3_write_buckets" {
+  type    = list(string)
+  default = []
+}
+
+variable "password_ref_arns" {
+  type    = list(string)
+  default = []
+}
+
+variable "iam_db_users" {
+  type    = list(string)
+  default = []
+}
+
+variable "rds_ca_bundle_url" {
+  default = "https://truststore.pki.rds.amazonaws.com/global/global-bundle.pem"
+}
+
+variable "config_kms_key_arns" {
+  type    = list(string)
+  default = []
+}
+
+variable "role_arns" {
+  type    = list(string)
+  default = []
+}
@@ -28,6 +28,19 @@ func GetEnvInt(envVar string, defaults int) int {
 	return i
 }
 
+// GetEnvBool returns a bool from the provided environment variable.
+func GetEnvBool(envVar string, defaults bool) bool {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return defaults
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
 // GetUnixTimeAsString returns the current unix time as a string
 func GetUnixTimeAsString() string {
 	currentTime := time.Now().Unix()